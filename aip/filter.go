@@ -0,0 +1,383 @@
+package aip
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/cel-go/cel"
+	exprpb "google.golang.org/genproto/googleapis/api/expr/v1alpha1"
+)
+
+// Option configures ParseFilter.
+type Option func(*translator)
+
+// Variable resolves the fields of the filter as the fields of the table variable. Otherwise, they
+// are resolved as top-level identifiers, such as the columns declared by cel2sql.RowTable.
+func Variable(name string) Option {
+	return func(t *translator) {
+		t.variable = name
+	}
+}
+
+// ParseFilter parses the AIP-160 filter, and returns the equivalent CEL AST checked in the
+// environment. An empty filter is parsed as `true`.
+//
+// Literals are coerced to the types of the fields they are compared with. Timestamps are RFC 3339
+// strings, and durations are strings such as "1.5s". String literals compared by `=` or `!=` may
+// contain `*` wildcards. The `:` operator tests the presence of a field with `*`, and the
+// membership of a value in a repeated field or of a key in a map.
+//
+// Parentheses and function calls may be nested up to 100 levels, beyond which *SyntaxError is
+// returned.
+func ParseFilter(env *cel.Env, filter string, opts ...Option) (*cel.Ast, error) {
+	n, err := parse(filter)
+	if err != nil {
+		return nil, err
+	}
+	t := &translator{env: env}
+	for _, opt := range opts {
+		opt(t)
+	}
+	source := "true"
+	if n != nil {
+		source, err = t.translate(n)
+		if err != nil {
+			return nil, err
+		}
+	}
+	ast, issues := env.Compile(source)
+	if issues != nil && issues.Err() != nil {
+		return nil, issues.Err()
+	}
+	if ast.ResultType().GetPrimitive() != exprpb.Type_BOOL {
+		return nil, fmt.Errorf("filter must be bool but %s", cel.FormatType(ast.ResultType()))
+	}
+	return ast, nil
+}
+
+type translator struct {
+	env      *cel.Env
+	variable string
+}
+
+func (t *translator) translate(n node) (string, error) {
+	switch n := n.(type) {
+	case *andNode:
+		return t.translateJunction(n.args, " && ")
+	case *orNode:
+		return t.translateJunction(n.args, " || ")
+	case *notNode:
+		arg, err := t.translate(n.arg)
+		if err != nil {
+			return "", err
+		}
+		return "!(" + arg + ")", nil
+	case *restrictionNode:
+		if n.comparator == "" {
+			if fn, ok := n.comparable.(*functionNode); ok {
+				return t.translateFunction(fn)
+			}
+			return "", &SyntaxError{Offset: n.offset, Message: "bare literals are not supported"}
+		}
+		return t.translateRestriction(n)
+	}
+	return "", fmt.Errorf("unsupported filter: %v", n)
+}
+
+func (t *translator) translateJunction(args []node, operator string) (string, error) {
+	translated := make([]string, len(args))
+	for i, arg := range args {
+		a, err := t.translate(arg)
+		if err != nil {
+			return "", err
+		}
+		translated[i] = "(" + a + ")"
+	}
+	return strings.Join(translated, operator), nil
+}
+
+func (t *translator) translateFunction(fn *functionNode) (string, error) {
+	for _, name := range fn.name {
+		if !identifierRegexp.MatchString(name) {
+			return "", &SyntaxError{Offset: fn.offset, Message: fmt.Sprintf("invalid function name \"%s\"", strings.Join(fn.name, "."))}
+		}
+	}
+	args := make([]string, len(fn.args))
+	for i, arg := range fn.args {
+		a, err := t.translateArg(arg)
+		if err != nil {
+			return "", err
+		}
+		args[i] = a
+	}
+	return strings.Join(fn.name, ".") + "(" + strings.Join(args, ", ") + ")", nil
+}
+
+// translateArg translates an argument of a function, which is a field if it can be resolved, or
+// a literal otherwise.
+func (t *translator) translateArg(n node) (string, error) {
+	switch n := n.(type) {
+	case *functionNode:
+		return t.translateFunction(n)
+	case *memberNode:
+		if !n.quoted {
+			if field, _, err := t.resolveField(n); err == nil {
+				return field, nil
+			}
+			if _, err := strconv.ParseInt(n.text(), 10, 64); err == nil {
+				return n.text(), nil
+			}
+			if _, err := strconv.ParseFloat(n.text(), 64); err == nil {
+				return formatDouble(n.text()), nil
+			}
+			if n.text() == "true" || n.text() == "false" {
+				return n.text(), nil
+			}
+		}
+		return strconv.Quote(n.text()), nil
+	}
+	return "", fmt.Errorf("unsupported function argument: %v", n)
+}
+
+var identifierRegexp = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+// resolveField returns the CEL expression of the member as a field, and its type.
+func (t *translator) resolveField(m *memberNode) (string, *exprpb.Type, error) {
+	if m.quoted {
+		return "", nil, &SyntaxError{Offset: m.offset, Message: "field must not be quoted"}
+	}
+	for _, part := range m.parts {
+		if !identifierRegexp.MatchString(part) {
+			return "", nil, &SyntaxError{Offset: m.offset, Message: fmt.Sprintf("invalid field \"%s\"", m.text())}
+		}
+	}
+	field := m.text()
+	if t.variable != "" {
+		field = t.variable + "." + field
+	}
+	ast, issues := t.env.Compile(field)
+	if issues != nil && issues.Err() != nil {
+		return "", nil, fmt.Errorf("unknown field \"%s\": %w", m.text(), issues.Err())
+	}
+	return field, ast.ResultType(), nil
+}
+
+var celComparators = map[string]string{
+	"=":  "==",
+	"!=": "!=",
+	"<":  "<",
+	"<=": "<=",
+	">":  ">",
+	">=": ">=",
+}
+
+func (t *translator) translateRestriction(r *restrictionNode) (string, error) {
+	var lhs string
+	var typ *exprpb.Type
+	switch c := r.comparable.(type) {
+	case *memberNode:
+		field, fieldType, err := t.resolveField(c)
+		if err != nil {
+			return "", err
+		}
+		lhs, typ = field, fieldType
+	case *functionNode:
+		fn, err := t.translateFunction(c)
+		if err != nil {
+			return "", err
+		}
+		ast, issues := t.env.Compile(fn)
+		if issues != nil && issues.Err() != nil {
+			return "", issues.Err()
+		}
+		lhs, typ = fn, ast.ResultType()
+	default:
+		return "", fmt.Errorf("unsupported comparable: %v", c)
+	}
+	return t.translateComparison(lhs, typ, r.comparator, r.arg, r.offset)
+}
+
+// translateComparison translates the comparison of the lhs with the arg. A composite arg such as
+// `a = (x OR y)` is distributed as `a = x OR a = y`.
+func (t *translator) translateComparison(lhs string, typ *exprpb.Type, comparator string, arg node, offset int) (string, error) {
+	switch a := arg.(type) {
+	case *andNode:
+		return t.translateComparisons(lhs, typ, comparator, a.args, offset, " && ")
+	case *orNode:
+		return t.translateComparisons(lhs, typ, comparator, a.args, offset, " || ")
+	case *notNode:
+		c, err := t.translateComparison(lhs, typ, comparator, a.arg, offset)
+		if err != nil {
+			return "", err
+		}
+		return "!(" + c + ")", nil
+	case *restrictionNode:
+		if a.comparator != "" {
+			return "", &SyntaxError{Offset: a.offset, Message: "comparison is not a value"}
+		}
+		return t.translateComparison(lhs, typ, comparator, a.comparable, offset)
+	case *functionNode:
+		fn, err := t.translateFunction(a)
+		if err != nil {
+			return "", err
+		}
+		if comparator == ":" {
+			return fn + " in " + lhs, nil
+		}
+		return lhs + " " + celComparators[comparator] + " " + fn, nil
+	case *memberNode:
+		if comparator == ":" {
+			return t.translateHas(lhs, typ, a, offset)
+		}
+		if a.quoted && typ.GetPrimitive() == exprpb.Type_STRING && strings.Contains(a.text(), "*") &&
+			(comparator == "=" || comparator == "!=") {
+			c := wildcard(lhs, a.text())
+			if comparator == "!=" {
+				return "!(" + c + ")", nil
+			}
+			return c, nil
+		}
+		value, err := literal(typ, a)
+		if err != nil {
+			return "", err
+		}
+		return lhs + " " + celComparators[comparator] + " " + value, nil
+	}
+	return "", fmt.Errorf("unsupported argument: %v", arg)
+}
+
+func (t *translator) translateComparisons(lhs string, typ *exprpb.Type, comparator string, args []node, offset int, operator string) (string, error) {
+	translated := make([]string, len(args))
+	for i, arg := range args {
+		c, err := t.translateComparison(lhs, typ, comparator, arg, offset)
+		if err != nil {
+			return "", err
+		}
+		translated[i] = "(" + c + ")"
+	}
+	return strings.Join(translated, operator), nil
+}
+
+func (t *translator) translateHas(lhs string, typ *exprpb.Type, arg *memberNode, offset int) (string, error) {
+	if !arg.quoted && arg.text() == "*" {
+		if !strings.Contains(lhs, ".") {
+			return "", &SyntaxError{Offset: offset, Message: "presence can be tested only for fields"}
+		}
+		return "has(" + lhs + ")", nil
+	}
+	switch kind := typ.GetTypeKind().(type) {
+	case *exprpb.Type_ListType_:
+		value, err := literal(kind.ListType.GetElemType(), arg)
+		if err != nil {
+			return "", err
+		}
+		return value + " in " + lhs, nil
+	case *exprpb.Type_MapType_:
+		value, err := literal(kind.MapType.GetKeyType(), arg)
+		if err != nil {
+			return "", err
+		}
+		return value + " in " + lhs, nil
+	}
+	value, err := literal(typ, arg)
+	if err != nil {
+		return "", err
+	}
+	return lhs + " == " + value, nil
+}
+
+// wildcard translates the string pattern with leading and/or trailing `*` wildcards, or with
+// wildcards in the middle as a regular expression.
+func wildcard(lhs string, pattern string) string {
+	trimmed := strings.Trim(pattern, "*")
+	if !strings.Contains(trimmed, "*") && trimmed != "" {
+		leading := strings.HasPrefix(pattern, "*")
+		trailing := strings.HasSuffix(pattern, "*")
+		switch {
+		case leading && trailing:
+			return lhs + ".contains(" + strconv.Quote(trimmed) + ")"
+		case leading:
+			return lhs + ".endsWith(" + strconv.Quote(trimmed) + ")"
+		case trailing:
+			return lhs + ".startsWith(" + strconv.Quote(trimmed) + ")"
+		}
+	}
+	parts := strings.Split(pattern, "*")
+	for i, part := range parts {
+		parts[i] = regexp.QuoteMeta(part)
+	}
+	return lhs + ".matches(" + strconv.Quote("^"+strings.Join(parts, ".*")+"$") + ")"
+}
+
+// literal returns the CEL literal of the value coerced to the type.
+func literal(typ *exprpb.Type, value *memberNode) (string, error) {
+	text := value.text()
+	if !value.quoted && text == "null" {
+		return "null", nil
+	}
+	invalid := func(typeName string) error {
+		return &SyntaxError{Offset: value.offset, Message: fmt.Sprintf("invalid %s \"%s\"", typeName, text)}
+	}
+	switch typ.GetPrimitive() {
+	case exprpb.Type_STRING:
+		return strconv.Quote(text), nil
+	case exprpb.Type_BYTES:
+		return "b" + strconv.Quote(text), nil
+	case exprpb.Type_BOOL:
+		if text != "true" && text != "false" {
+			return "", invalid("bool")
+		}
+		return text, nil
+	case exprpb.Type_INT64:
+		i, err := strconv.ParseInt(text, 10, 64)
+		if err != nil {
+			return "", invalid("int")
+		}
+		return strconv.FormatInt(i, 10), nil
+	case exprpb.Type_UINT64:
+		u, err := strconv.ParseUint(text, 10, 64)
+		if err != nil {
+			return "", invalid("uint")
+		}
+		return strconv.FormatUint(u, 10) + "u", nil
+	case exprpb.Type_DOUBLE:
+		if _, err := strconv.ParseFloat(text, 64); err != nil {
+			return "", invalid("double")
+		}
+		return formatDouble(text), nil
+	}
+	switch typ.GetWellKnown() {
+	case exprpb.Type_TIMESTAMP:
+		if _, err := time.Parse(time.RFC3339Nano, text); err != nil {
+			return "", invalid("timestamp")
+		}
+		return "timestamp(" + strconv.Quote(text) + ")", nil
+	case exprpb.Type_DURATION:
+		if _, err := time.ParseDuration(text); err != nil {
+			return "", invalid("duration")
+		}
+		return "duration(" + strconv.Quote(text) + ")", nil
+	}
+	switch typ.GetAbstractType().GetName() {
+	case "DATE":
+		return "date(" + strconv.Quote(text) + ")", nil
+	case "TIME":
+		return "time(" + strconv.Quote(text) + ")", nil
+	case "DATETIME":
+		return "datetime(" + strconv.Quote(text) + ")", nil
+	}
+	return "", &SyntaxError{Offset: value.offset, Message: fmt.Sprintf("cannot compare with %s", cel.FormatType(typ))}
+}
+
+// formatDouble ensures that the number is a CEL double literal, e.g. `1.0` instead of `1`.
+func formatDouble(text string) string {
+	f, _ := strconv.ParseFloat(text, 64)
+	s := strconv.FormatFloat(f, 'g', -1, 64)
+	if !strings.ContainsAny(s, ".eEn") {
+		s += ".0"
+	}
+	return s
+}
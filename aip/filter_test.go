@@ -0,0 +1,244 @@
+package aip_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"cloud.google.com/go/bigquery"
+	"github.com/google/cel-go/checker/decls"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/cockscomb/cel2sql"
+	"github.com/cockscomb/cel2sql/aip"
+	"github.com/cockscomb/cel2sql/sqltypes"
+	"github.com/cockscomb/cel2sql/test"
+)
+
+func TestParseFilter(t *testing.T) {
+	env, err := cel2sql.NewEnv(
+		map[string]bigquery.Schema{
+			"trigrams":  test.NewTrigramsTableMetadata().Schema,
+			"wikipedia": test.NewWikipediaTableMetadata().Schema,
+		},
+		cel2sql.TableVariable("trigram", "trigrams"),
+		cel2sql.TableVariable("page", "wikipedia"),
+		cel2sql.Declarations(
+			decls.NewVar("created", decls.Timestamp),
+			decls.NewVar("elapsed", decls.Duration),
+			decls.NewVar("day", sqltypes.Date),
+			decls.NewVar("tags", decls.NewListType(decls.String)),
+			decls.NewVar("labels", decls.NewMapType(decls.String, decls.String)),
+		),
+	)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name    string
+		filter  string
+		opts    []aip.Option
+		want    string
+		wantErr bool
+	}{
+		{
+			name:   "empty",
+			filter: "",
+			want:   "TRUE",
+		},
+		{
+			name:   "equals",
+			filter: `title = "Tokyo"`,
+			opts:   []aip.Option{aip.Variable("page")},
+			want:   "`page`.`title` = \"Tokyo\"",
+		},
+		{
+			name:   "unquotedString",
+			filter: `language = en`,
+			opts:   []aip.Option{aip.Variable("page")},
+			want:   "`page`.`language` = \"en\"",
+		},
+		{
+			name:   "comparators",
+			filter: `id > 1 AND id <= 10 AND wp_namespace != 0`,
+			opts:   []aip.Option{aip.Variable("page")},
+			want:   "`page`.`id` > 1 AND `page`.`id` <= 10 AND `page`.`wp_namespace` != 0",
+		},
+		{
+			name:   "implicitAnd",
+			filter: `is_redirect = false is_bot = true`,
+			opts:   []aip.Option{aip.Variable("page")},
+			want:   "`page`.`is_redirect` IS FALSE AND `page`.`is_bot` IS TRUE",
+		},
+		{
+			name:   "orBindsTighterThanAnd",
+			filter: `id = 1 AND id = 2 OR id = 3`,
+			opts:   []aip.Option{aip.Variable("page")},
+			want:   "`page`.`id` = 1 AND (`page`.`id` = 2 OR `page`.`id` = 3)",
+		},
+		{
+			name:   "not",
+			filter: `NOT is_bot = true AND -is_minor = true`,
+			opts:   []aip.Option{aip.Variable("page")},
+			want:   "NOT (`page`.`is_bot` IS TRUE) AND NOT (`page`.`is_minor` IS TRUE)",
+		},
+		{
+			name:   "negativeNumber",
+			filter: `wp_namespace = -1`,
+			opts:   []aip.Option{aip.Variable("page")},
+			want:   "`page`.`wp_namespace` = -1",
+		},
+		{
+			name:   "compositeArg",
+			filter: `language = (en OR ja)`,
+			opts:   []aip.Option{aip.Variable("page")},
+			want:   "`page`.`language` = \"en\" OR `page`.`language` = \"ja\"",
+		},
+		{
+			name:    "nestedField",
+			filter:  `cell.page_count > 1`,
+			opts:    []aip.Option{aip.Variable("trigram")},
+			wantErr: true,
+		},
+		{
+			name:   "prefixWildcard",
+			filter: `title = "Tok*"`,
+			opts:   []aip.Option{aip.Variable("page")},
+			want:   "STARTS_WITH(`page`.`title`, \"Tok\")",
+		},
+		{
+			name:   "suffixWildcard",
+			filter: `title = "*kyo"`,
+			opts:   []aip.Option{aip.Variable("page")},
+			want:   "ENDS_WITH(`page`.`title`, \"kyo\")",
+		},
+		{
+			name:   "containsWildcard",
+			filter: `title != "*ok*"`,
+			opts:   []aip.Option{aip.Variable("page")},
+			want:   "NOT INSTR(`page`.`title`, \"ok\") != 0",
+		},
+		{
+			name:   "middleWildcard",
+			filter: `title = "T*o.k*"`,
+			opts:   []aip.Option{aip.Variable("page")},
+			want:   "REGEXP_CONTAINS(`page`.`title`, \"^T.*o\\\\.k.*$\")",
+		},
+		{
+			name:   "has",
+			filter: `title:*`,
+			opts:   []aip.Option{aip.Variable("page")},
+			want:   "`page`.`title` IS NOT NULL",
+		},
+		{
+			name:   "hasScalar",
+			filter: `title:Tokyo`,
+			opts:   []aip.Option{aip.Variable("page")},
+			want:   "`page`.`title` = \"Tokyo\"",
+		},
+		{
+			name:   "hasList",
+			filter: `tags:go`,
+			want:   "\"go\" IN UNNEST(`tags`)",
+		},
+		{
+			name:   "timestamp",
+			filter: `created > "2021-01-01T00:00:00Z"`,
+			want:   "`created` > TIMESTAMP(\"2021-01-01T00:00:00Z\")",
+		},
+		{
+			name:   "duration",
+			filter: `elapsed < 1.5s`,
+			want:   "`elapsed` < INTERVAL 1500 MILLISECOND",
+		},
+		{
+			name:   "date",
+			filter: `day = "2021-01-01"`,
+			want:   "`day` = DATE(\"2021-01-01\")",
+		},
+		{
+			name:   "function",
+			filter: `page.title.startsWith("a")`,
+			want:   "STARTS_WITH(`page`.`title`, \"a\")",
+		},
+		{
+			name:    "invalidTimestamp",
+			filter:  `created > yesterday`,
+			wantErr: true,
+		},
+		{
+			name:    "invalidInt",
+			filter:  `id = one`,
+			opts:    []aip.Option{aip.Variable("page")},
+			wantErr: true,
+		},
+		{
+			name:    "unknownField",
+			filter:  `unknown = 1`,
+			opts:    []aip.Option{aip.Variable("page")},
+			wantErr: true,
+		},
+		{
+			name:    "bareLiteral",
+			filter:  `Tokyo`,
+			wantErr: true,
+		},
+		{
+			name:    "hasNotField",
+			filter:  `created:*`,
+			wantErr: true,
+		},
+		{
+			name:    "unbalanced",
+			filter:  `(id = 1`,
+			opts:    []aip.Option{aip.Variable("page")},
+			wantErr: true,
+		},
+		{
+			name:    "unterminated",
+			filter:  `title = "Tokyo`,
+			opts:    []aip.Option{aip.Variable("page")},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ast, err := aip.ParseFilter(env, tt.filter, tt.opts...)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			got, err := cel2sql.Convert(ast)
+			if assert.NoError(t, err) {
+				assert.Equal(t, tt.want, got)
+			}
+		})
+	}
+}
+
+func TestParseFilter_syntaxError(t *testing.T) {
+	env, err := cel2sql.NewEnv(nil, cel2sql.Declarations(decls.NewVar("id", decls.Int)))
+	require.NoError(t, err)
+
+	_, err = aip.ParseFilter(env, `id = 1 AND`)
+	var syntaxError *aip.SyntaxError
+	if assert.True(t, errors.As(err, &syntaxError)) {
+		assert.Equal(t, 10, syntaxError.Offset)
+	}
+}
+
+func TestParseFilter_nestingDepth(t *testing.T) {
+	env, err := cel2sql.NewEnv(nil, cel2sql.Declarations(decls.NewVar("age", decls.Int)))
+	require.NoError(t, err)
+
+	_, err = aip.ParseFilter(env, strings.Repeat("(", 100)+"age = 1"+strings.Repeat(")", 100))
+	assert.NoError(t, err)
+
+	const depth = 3000000
+	_, err = aip.ParseFilter(env, strings.Repeat("(", depth)+"age = 1"+strings.Repeat(")", depth))
+	var syntaxError *aip.SyntaxError
+	if assert.True(t, errors.As(err, &syntaxError), "%v", err) {
+		assert.Equal(t, 100, syntaxError.Offset)
+	}
+}
@@ -0,0 +1,155 @@
+package aip
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenText
+	tokenString
+	tokenDot
+	tokenComma
+	tokenLParen
+	tokenRParen
+	tokenComparator
+	tokenAnd
+	tokenOr
+	tokenNot
+	tokenMinus
+)
+
+type token struct {
+	kind   tokenKind
+	value  string
+	offset int
+}
+
+// SyntaxError reports a malformed filter.
+type SyntaxError struct {
+	// Offset is the byte offset in the filter at which the error occurred.
+	Offset  int
+	Message string
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("syntax error at offset %d: %s", e.Offset, e.Message)
+}
+
+func isTextRune(r rune) bool {
+	if unicode.IsSpace(r) {
+		return false
+	}
+	return !strings.ContainsRune(`."',()=<>!:`, r)
+}
+
+// tokenize splits the filter into tokens. Whitespace is significant only as a separator.
+func tokenize(filter string) ([]token, error) {
+	var tokens []token
+	for i := 0; i < len(filter); {
+		r, size := utf8.DecodeRuneInString(filter[i:])
+		switch {
+		case unicode.IsSpace(r):
+			i += size
+		case r == '"' || r == '\'':
+			value, n, err := unquote(filter[i:])
+			if err != nil {
+				return nil, &SyntaxError{Offset: i, Message: err.Error()}
+			}
+			tokens = append(tokens, token{kind: tokenString, value: value, offset: i})
+			i += n
+		case r == '.':
+			tokens = append(tokens, token{kind: tokenDot, value: ".", offset: i})
+			i++
+		case r == ',':
+			tokens = append(tokens, token{kind: tokenComma, value: ",", offset: i})
+			i++
+		case r == '(':
+			tokens = append(tokens, token{kind: tokenLParen, value: "(", offset: i})
+			i++
+		case r == ')':
+			tokens = append(tokens, token{kind: tokenRParen, value: ")", offset: i})
+			i++
+		case r == '<' || r == '>' || r == '!':
+			if strings.HasPrefix(filter[i+1:], "=") {
+				tokens = append(tokens, token{kind: tokenComparator, value: filter[i : i+2], offset: i})
+				i += 2
+			} else if r == '!' {
+				return nil, &SyntaxError{Offset: i, Message: "unexpected \"!\""}
+			} else {
+				tokens = append(tokens, token{kind: tokenComparator, value: filter[i : i+1], offset: i})
+				i++
+			}
+		case r == '=' || r == ':':
+			tokens = append(tokens, token{kind: tokenComparator, value: filter[i : i+1], offset: i})
+			i++
+		default:
+			start := i
+			for i < len(filter) {
+				r, size := utf8.DecodeRuneInString(filter[i:])
+				if !isTextRune(r) {
+					break
+				}
+				i += size
+			}
+			text := filter[start:i]
+			switch {
+			case text == "AND":
+				tokens = append(tokens, token{kind: tokenAnd, value: text, offset: start})
+			case text == "OR":
+				tokens = append(tokens, token{kind: tokenOr, value: text, offset: start})
+			case text == "NOT":
+				tokens = append(tokens, token{kind: tokenNot, value: text, offset: start})
+			case text == "-":
+				tokens = append(tokens, token{kind: tokenMinus, value: text, offset: start})
+			case strings.HasPrefix(text, "-") && !startsWithDigit(text[1:]):
+				tokens = append(tokens, token{kind: tokenMinus, value: "-", offset: start})
+				tokens = append(tokens, token{kind: tokenText, value: text[1:], offset: start + 1})
+			default:
+				tokens = append(tokens, token{kind: tokenText, value: text, offset: start})
+			}
+		}
+	}
+	tokens = append(tokens, token{kind: tokenEOF, offset: len(filter)})
+	return tokens, nil
+}
+
+func startsWithDigit(s string) bool {
+	return s != "" && s[0] >= '0' && s[0] <= '9'
+}
+
+// unquote reads a quoted string at the beginning of s, and returns its value and length.
+func unquote(s string) (string, int, error) {
+	quote := s[0]
+	var b strings.Builder
+	for i := 1; i < len(s); i++ {
+		c := s[i]
+		switch c {
+		case quote:
+			return b.String(), i + 1, nil
+		case '\\':
+			if i+1 >= len(s) {
+				return "", 0, fmt.Errorf("unterminated string")
+			}
+			i++
+			switch s[i] {
+			case 'n':
+				b.WriteByte('\n')
+			case 'r':
+				b.WriteByte('\r')
+			case 't':
+				b.WriteByte('\t')
+			default:
+				b.WriteByte(s[i])
+			}
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return "", 0, fmt.Errorf("unterminated string")
+}
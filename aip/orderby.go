@@ -0,0 +1,67 @@
+package aip
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/google/cel-go/cel"
+
+	"github.com/cockscomb/cel2sql"
+)
+
+// ParseOrderBy parses the AIP-132 order_by, e.g. `create_time desc, display_name`, and returns the
+// orderings of the fields. Each field path is validated in the environment, and must not be
+// repeated or a RECORD.
+//
+// The orderings are rendered by cel2sql.OrderByClause with the same variable.
+func ParseOrderBy(env *cel.Env, orderBy string, opts ...Option) ([]cel2sql.Ordering, error) {
+	t := &translator{env: env}
+	for _, opt := range opts {
+		opt(t)
+	}
+	if strings.TrimSpace(orderBy) == "" {
+		return nil, nil
+	}
+	var orderings []cel2sql.Ordering
+	seen := map[string]bool{}
+	offset := 0
+	for _, term := range strings.Split(orderBy, ",") {
+		ordering, err := t.parseOrdering(term, offset)
+		if err != nil {
+			return nil, err
+		}
+		if seen[ordering.Field] {
+			return nil, &SyntaxError{Offset: offset, Message: fmt.Sprintf("duplicate field \"%s\"", ordering.Field)}
+		}
+		seen[ordering.Field] = true
+		orderings = append(orderings, ordering)
+		offset += len(term) + 1
+	}
+	if _, err := cel2sql.OrderByClause(env, t.variable, orderings); err != nil {
+		return nil, err
+	}
+	return orderings, nil
+}
+
+func (t *translator) parseOrdering(term string, offset int) (cel2sql.Ordering, error) {
+	words := strings.Fields(term)
+	if len(words) == 0 || len(words) > 2 {
+		return cel2sql.Ordering{}, &SyntaxError{Offset: offset, Message: fmt.Sprintf("invalid ordering \"%s\"", strings.TrimSpace(term))}
+	}
+	ordering := cel2sql.Ordering{Field: words[0], Direction: cel2sql.Ascending}
+	if len(words) == 2 {
+		switch words[1] {
+		case "asc":
+		case "desc":
+			ordering.Direction = cel2sql.Descending
+		default:
+			return cel2sql.Ordering{}, &SyntaxError{Offset: offset, Message: fmt.Sprintf("invalid direction \"%s\"", words[1])}
+		}
+	}
+	for _, name := range strings.Split(ordering.Field, ".") {
+		if !identifierRegexp.MatchString(name) {
+			return cel2sql.Ordering{}, &SyntaxError{Offset: offset, Message: fmt.Sprintf("invalid field \"%s\"", ordering.Field)}
+		}
+	}
+	return ordering, nil
+}
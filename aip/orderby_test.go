@@ -0,0 +1,147 @@
+package aip_test
+
+import (
+	"testing"
+
+	"cloud.google.com/go/bigquery"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/cockscomb/cel2sql"
+	"github.com/cockscomb/cel2sql/aip"
+	"github.com/cockscomb/cel2sql/bq"
+	"github.com/cockscomb/cel2sql/test"
+)
+
+func TestParseOrderBy(t *testing.T) {
+	books := bigquery.Schema{
+		{Name: "title", Type: bigquery.StringFieldType},
+		{Name: "create_time", Type: bigquery.TimestampFieldType},
+		{Name: "tags", Type: bigquery.StringFieldType, Repeated: true},
+		{Name: "author", Type: bigquery.RecordFieldType, Schema: bigquery.Schema{
+			{Name: "display_name", Type: bigquery.StringFieldType},
+		}},
+	}
+	env, err := cel2sql.NewEnv(
+		map[string]bigquery.Schema{
+			"books":     books,
+			"wikipedia": test.NewWikipediaTableMetadata().Schema,
+		},
+		cel2sql.TableVariable("book", "books"),
+		cel2sql.RowTable("wikipedia"),
+		cel2sql.ProviderOptions(bq.ColumnNames(bq.NameMap{
+			"books.author": {"displayName": "display_name"},
+		})),
+	)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name    string
+		orderBy string
+		opts    []aip.Option
+		convert []cel2sql.ConvertOption
+		want    []cel2sql.Ordering
+		wantSQL string
+		wantErr bool
+	}{
+		{
+			name:    "empty",
+			orderBy: " ",
+			opts:    []aip.Option{aip.Variable("book")},
+		},
+		{
+			name:    "directions",
+			orderBy: "create_time desc, title",
+			opts:    []aip.Option{aip.Variable("book")},
+			want: []cel2sql.Ordering{
+				{Field: "create_time", Direction: cel2sql.Descending},
+				{Field: "title", Direction: cel2sql.Ascending},
+			},
+			wantSQL: "ORDER BY `book`.`create_time` DESC NULLS LAST, `book`.`title` ASC NULLS FIRST",
+		},
+		{
+			name:    "nested",
+			orderBy: "author.displayName asc",
+			opts:    []aip.Option{aip.Variable("book")},
+			convert: []cel2sql.ConvertOption{cel2sql.ColumnNames(bq.NameMap{
+				"books.author": {"displayName": "display_name"},
+			})},
+			want: []cel2sql.Ordering{
+				{Field: "author.displayName", Direction: cel2sql.Ascending},
+			},
+			wantSQL: "ORDER BY `book`.`author`.`display_name` ASC NULLS FIRST",
+		},
+		{
+			name:    "rowColumns",
+			orderBy: "id desc",
+			want: []cel2sql.Ordering{
+				{Field: "id", Direction: cel2sql.Descending},
+			},
+			wantSQL: "ORDER BY `id` DESC NULLS LAST",
+		},
+		{
+			name:    "repeated",
+			orderBy: "tags",
+			opts:    []aip.Option{aip.Variable("book")},
+			wantErr: true,
+		},
+		{
+			name:    "record",
+			orderBy: "author",
+			opts:    []aip.Option{aip.Variable("book")},
+			wantErr: true,
+		},
+		{
+			name:    "unknown",
+			orderBy: "publisher",
+			opts:    []aip.Option{aip.Variable("book")},
+			wantErr: true,
+		},
+		{
+			name:    "invalidDirection",
+			orderBy: "title descending",
+			opts:    []aip.Option{aip.Variable("book")},
+			wantErr: true,
+		},
+		{
+			name:    "duplicate",
+			orderBy: "title, title desc",
+			opts:    []aip.Option{aip.Variable("book")},
+			wantErr: true,
+		},
+		{
+			name:    "emptyTerm",
+			orderBy: "title,",
+			opts:    []aip.Option{aip.Variable("book")},
+			wantErr: true,
+		},
+		{
+			name:    "invalidField",
+			orderBy: "title || true",
+			opts:    []aip.Option{aip.Variable("book")},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := aip.ParseOrderBy(env, tt.orderBy, tt.opts...)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+			if tt.wantSQL == "" {
+				return
+			}
+			variable := ""
+			if len(tt.opts) > 0 {
+				variable = "book"
+			}
+			sql, err := cel2sql.OrderByClause(env, variable, got, tt.convert...)
+			if assert.NoError(t, err) {
+				assert.Equal(t, tt.wantSQL, sql)
+			}
+		})
+	}
+}
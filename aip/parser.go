@@ -0,0 +1,285 @@
+package aip
+
+import (
+	"fmt"
+	"strings"
+)
+
+// The syntax tree of filters, following the grammar of https://google.aip.dev/assets/misc/ebnf-filtering.txt
+
+type node interface{}
+
+type andNode struct {
+	args []node
+}
+
+type orNode struct {
+	args []node
+}
+
+type notNode struct {
+	arg node
+}
+
+type restrictionNode struct {
+	comparable node
+	comparator string
+	arg        node
+	offset     int
+}
+
+// memberNode is a value optionally followed by fields, e.g. `a.b.c` or `"text"`.
+type memberNode struct {
+	parts []string
+	// quoted indicates whether the value is a string literal.
+	quoted bool
+	offset int
+}
+
+func (m *memberNode) text() string {
+	return strings.Join(m.parts, ".")
+}
+
+type functionNode struct {
+	name   []string
+	args   []node
+	offset int
+}
+
+type parser struct {
+	tokens []token
+	pos    int
+	depth  int
+}
+
+// maxDepth limits the nesting of composites and function calls, so that a deeply nested filter of
+// a client cannot overflow the stack.
+const maxDepth = 100
+
+func parse(filter string) (node, error) {
+	tokens, err := tokenize(filter)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{tokens: tokens}
+	if p.peek().kind == tokenEOF {
+		return nil, nil
+	}
+	n, err := p.parseExpression()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != tokenEOF {
+		return nil, p.unexpected(t)
+	}
+	return n, nil
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != tokenEOF {
+		p.pos++
+	}
+	return t
+}
+
+// enter increments the nesting depth at the token, returning *SyntaxError beyond the limit.
+func (p *parser) enter(t token) error {
+	p.depth++
+	if p.depth > maxDepth {
+		return &SyntaxError{Offset: t.offset, Message: fmt.Sprintf("filter is nested deeper than %d", maxDepth)}
+	}
+	return nil
+}
+
+func (p *parser) leave() {
+	p.depth--
+}
+
+func (p *parser) unexpected(t token) error {
+	if t.kind == tokenEOF {
+		return &SyntaxError{Offset: t.offset, Message: "unexpected end of filter"}
+	}
+	return &SyntaxError{Offset: t.offset, Message: fmt.Sprintf("unexpected \"%s\"", t.value)}
+}
+
+// expression : sequence {WS AND WS sequence}
+func (p *parser) parseExpression() (node, error) {
+	var args []node
+	for {
+		n, err := p.parseSequence()
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, n)
+		if p.peek().kind != tokenAnd {
+			break
+		}
+		p.next()
+	}
+	if len(args) == 1 {
+		return args[0], nil
+	}
+	return &andNode{args: args}, nil
+}
+
+// sequence : factor {WS factor}
+func (p *parser) parseSequence() (node, error) {
+	var args []node
+	for {
+		n, err := p.parseFactor()
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, n)
+		if !p.startsFactor() {
+			break
+		}
+	}
+	if len(args) == 1 {
+		return args[0], nil
+	}
+	return &andNode{args: args}, nil
+}
+
+func (p *parser) startsFactor() bool {
+	switch p.peek().kind {
+	case tokenText, tokenString, tokenLParen, tokenNot, tokenMinus:
+		return true
+	}
+	return false
+}
+
+// factor : term {WS OR WS term}
+func (p *parser) parseFactor() (node, error) {
+	var args []node
+	for {
+		n, err := p.parseTerm()
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, n)
+		if p.peek().kind != tokenOr {
+			break
+		}
+		p.next()
+	}
+	if len(args) == 1 {
+		return args[0], nil
+	}
+	return &orNode{args: args}, nil
+}
+
+// term : [(NOT WS | MINUS)] simple
+func (p *parser) parseTerm() (node, error) {
+	if k := p.peek().kind; k == tokenNot || k == tokenMinus {
+		p.next()
+		n, err := p.parseSimple()
+		if err != nil {
+			return nil, err
+		}
+		return &notNode{arg: n}, nil
+	}
+	return p.parseSimple()
+}
+
+// simple : restriction | composite
+func (p *parser) parseSimple() (node, error) {
+	if p.peek().kind == tokenLParen {
+		return p.parseComposite()
+	}
+	return p.parseRestriction()
+}
+
+// composite : LPAREN expression RPAREN
+func (p *parser) parseComposite() (node, error) {
+	if err := p.enter(p.next()); err != nil {
+		return nil, err
+	}
+	defer p.leave()
+	n, err := p.parseExpression()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.next(); t.kind != tokenRParen {
+		return nil, p.unexpected(t)
+	}
+	return n, nil
+}
+
+// restriction : comparable [comparator arg]
+func (p *parser) parseRestriction() (node, error) {
+	offset := p.peek().offset
+	comparable, err := p.parseComparable()
+	if err != nil {
+		return nil, err
+	}
+	if p.peek().kind != tokenComparator {
+		return &restrictionNode{comparable: comparable, offset: offset}, nil
+	}
+	comparator := p.next().value
+	arg, err := p.parseArg()
+	if err != nil {
+		return nil, err
+	}
+	return &restrictionNode{comparable: comparable, comparator: comparator, arg: arg, offset: offset}, nil
+}
+
+// arg : comparable | composite
+func (p *parser) parseArg() (node, error) {
+	if p.peek().kind == tokenLParen {
+		return p.parseComposite()
+	}
+	return p.parseComparable()
+}
+
+// comparable : member | function
+// member : value {DOT field}
+// function : name {DOT name} LPAREN [argList] RPAREN
+func (p *parser) parseComparable() (node, error) {
+	t := p.next()
+	if t.kind != tokenText && t.kind != tokenString {
+		return nil, p.unexpected(t)
+	}
+	m := &memberNode{parts: []string{t.value}, quoted: t.kind == tokenString, offset: t.offset}
+	for p.peek().kind == tokenDot {
+		p.next()
+		f := p.next()
+		switch f.kind {
+		case tokenText, tokenString, tokenAnd, tokenOr, tokenNot:
+			m.parts = append(m.parts, f.value)
+		default:
+			return nil, p.unexpected(f)
+		}
+	}
+	if p.peek().kind != tokenLParen || m.quoted {
+		return m, nil
+	}
+	if err := p.enter(p.next()); err != nil {
+		return nil, err
+	}
+	defer p.leave()
+	fn := &functionNode{name: m.parts, offset: m.offset}
+	if p.peek().kind == tokenRParen {
+		p.next()
+		return fn, nil
+	}
+	for {
+		arg, err := p.parseArg()
+		if err != nil {
+			return nil, err
+		}
+		fn.args = append(fn.args, arg)
+		t := p.next()
+		if t.kind == tokenRParen {
+			return fn, nil
+		}
+		if t.kind != tokenComma {
+			return nil, p.unexpected(t)
+		}
+	}
+}
@@ -0,0 +1,577 @@
+package cel2sql
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/civil"
+	"github.com/google/cel-go/cel"
+	"github.com/google/cel-go/common/operators"
+	"github.com/google/cel-go/common/overloads"
+	"github.com/google/cel-go/common/types"
+	"github.com/google/cel-go/common/types/ref"
+	"github.com/google/cel-go/common/types/traits"
+	"github.com/google/cel-go/parser"
+	exprpb "google.golang.org/genproto/googleapis/api/expr/v1alpha1"
+
+	"github.com/cockscomb/cel2sql/composite"
+)
+
+// AnalysisOption configures the static analyses of filters, such as Implies.
+type AnalysisOption func(*analyzer)
+
+// AnalysisMaxClauses limits the number of clauses of the normal forms of the filters analyzed. The
+// analyses exceeding the limit give up conservatively. It defaults to DefaultMaxClauses.
+func AnalysisMaxClauses(n int) AnalysisOption {
+	return func(a *analyzer) {
+		a.maxClauses = n
+	}
+}
+
+// FieldNullability determines whether fields may be NULL by the type provider, e.g. the provider
+// of the environment. Only the fields which the provider reports as required, and the fields of
+// the table variables, are assumed not to be NULL. Providers which do not implement
+// composite.Nullability treat all the fields as nullable, which is the default.
+func FieldNullability(provider ref.TypeProvider) AnalysisOption {
+	return func(a *analyzer) {
+		a.nullability, _ = provider.(composite.Nullability)
+	}
+}
+
+type analyzer struct {
+	maxClauses  int
+	nullability composite.Nullability
+}
+
+func newAnalyzer(opts []AnalysisOption) *analyzer {
+	a := &analyzer{maxClauses: DefaultMaxClauses}
+	for _, opt := range opts {
+		opt(a)
+	}
+	return a
+}
+
+// Implies reports whether the filter a implies the filter b, i.e. every row selected by a is also
+// selected by b, e.g. `x > 10` implies `x > 5`, and `x == "a"` implies `x in ["a", "b"]`. Both
+// filters must be checked in the same environment.
+//
+// The analysis is conservative: false means that the implication could not be proven. It reasons
+// about comparisons of fields with literals, `in` lists of literals, and otherwise identical
+// sub-expressions.
+func Implies(a, b *cel.Ast, opts ...AnalysisOption) (bool, error) {
+	an := newAnalyzer(opts)
+	// a implies b if every conjunction of the DNF of a implies every disjunction of the CNF of b.
+	premises, ok, err := an.clauses(a, DisjunctiveNormalForm)
+	if err != nil || !ok {
+		return false, err
+	}
+	conclusions, ok, err := an.clauses(b, ConjunctiveNormalForm)
+	if err != nil || !ok {
+		return false, err
+	}
+	for _, premise := range premises {
+		if an.unsatisfiable(premise) {
+			continue
+		}
+		for _, conclusion := range conclusions {
+			if !an.impliesClause(premise, conclusion) {
+				return false, nil
+			}
+		}
+	}
+	return true, nil
+}
+
+// IsContradiction reports whether the boolean filter never selects any row, e.g.
+// `x > 30 && x < 20`, so the query need not run. It is conservative: false means that the
+// contradiction could not be proven.
+func IsContradiction(ast *cel.Ast, opts ...AnalysisOption) (bool, error) {
+	if ast.ResultType().GetPrimitive() != exprpb.Type_BOOL {
+		return false, fmt.Errorf("expression must be bool but %s", cel.FormatType(ast.ResultType()))
+	}
+	an := newAnalyzer(opts)
+	clauses, ok, err := an.clauses(ast, DisjunctiveNormalForm)
+	if err != nil || !ok {
+		return false, err
+	}
+	for _, clause := range clauses {
+		if !an.unsatisfiable(clause) {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// IsTautology reports whether the boolean filter selects every row, e.g. `x == "a" || x != "a"`.
+// As the comparisons with NULL are not true in SQL, the fields compared must be known not to be
+// NULL by FieldNullability. It is conservative: false means that the tautology could not be
+// proven.
+func IsTautology(ast *cel.Ast, opts ...AnalysisOption) (bool, error) {
+	if ast.ResultType().GetPrimitive() != exprpb.Type_BOOL {
+		return false, fmt.Errorf("expression must be bool but %s", cel.FormatType(ast.ResultType()))
+	}
+	an := newAnalyzer(opts)
+	clauses, ok, err := an.clauses(ast, ConjunctiveNormalForm)
+	if err != nil || !ok {
+		return false, err
+	}
+	for _, clause := range clauses {
+		// the disjunction is always true if the conjunction of the negated literals is never true.
+		negated := make([]literal, len(clause))
+		for i, l := range clause {
+			if !l.nonNull {
+				return false, nil
+			}
+			negated[i] = l.negate()
+		}
+		if !an.unsatisfiable(negated) {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// impliesClause reports whether the conjunction of the premise implies the disjunction of the
+// conclusion, i.e. the premise and the negation of the conclusion are unsatisfiable.
+func (an *analyzer) impliesClause(premise, conclusion []literal) bool {
+	// In SQL, a literal of the conclusion is neither true nor false when its field is NULL. The
+	// literals whose fields are neither constrained by the premise, which are not NULL when it is
+	// true, nor required are dropped, as the premise implies the clause if it implies the rest.
+	constrained := map[string]bool{}
+	for _, l := range premise {
+		constrained[l.key()] = true
+	}
+	literals := append([]literal{}, premise...)
+	for _, l := range conclusion {
+		if !constrained[l.key()] && !l.nonNull {
+			continue
+		}
+		literals = append(literals, l.negate())
+	}
+	return an.unsatisfiable(literals)
+}
+
+// clauses returns the clauses of the normal form of the boolean AST. It returns false if the
+// normal form exceeds the limit.
+func (an *analyzer) clauses(ast *cel.Ast, form NormalForm) ([][]literal, bool, error) {
+	checkedExpr, err := cel.AstToCheckedExpr(ast)
+	if err != nil {
+		return nil, false, err
+	}
+	n := newNormalizer(checkedExpr, form)
+	n.maxClauses = an.maxClauses
+	exprClauses, err := n.clauses(checkedExpr.GetExpr(), false)
+	if _, exceeded := err.(*ClauseLimitError); exceeded {
+		return nil, false, nil
+	} else if err != nil {
+		return nil, false, err
+	}
+	clauses := make([][]literal, len(exprClauses))
+	for i, exprClause := range exprClauses {
+		for _, expr := range exprClause {
+			l := parseLiteral(checkedExpr, expr)
+			l.nonNull = l.comparison != nil && an.isNonNull(checkedExpr, l.comparison.operand)
+			clauses[i] = append(clauses[i], l)
+		}
+	}
+	return clauses, true, nil
+}
+
+// literal is an atomic boolean expression or its negation.
+type literal struct {
+	atom    *exprpb.Expr
+	negated bool
+	// comparison is the comparison of a field with literals, or nil if the atom is opaque.
+	comparison *comparison
+	source     string
+	// nonNull is true if the field of the comparison is never NULL.
+	nonNull bool
+}
+
+func parseLiteral(checkedExpr *exprpb.CheckedExpr, expr *exprpb.Expr) literal {
+	l := literal{atom: expr}
+	if c := expr.GetCallExpr(); c.GetFunction() == operators.LogicalNot {
+		l.atom, l.negated = c.GetArgs()[0], true
+	}
+	l.comparison = parseComparison(checkedExpr, l.atom)
+	if l.comparison != nil && l.negated {
+		l.comparison = l.comparison.negate()
+	}
+	l.source, _ = parser.Unparse(l.atom, checkedExpr.GetSourceInfo())
+	return l
+}
+
+// key identifies the field of the comparison, or the atom.
+func (l literal) key() string {
+	if l.comparison != nil {
+		return "field:" + l.comparison.field
+	}
+	return "atom:" + l.source
+}
+
+func (l literal) negate() literal {
+	negated := l
+	negated.negated = !l.negated
+	if l.comparison != nil {
+		negated.comparison = l.comparison.negate()
+	}
+	return negated
+}
+
+// unsatisfiable reports whether the conjunction of the literals is always false. It returns false
+// when it cannot be proven.
+func (an *analyzer) unsatisfiable(literals []literal) bool {
+	polarities := map[string]bool{}
+	domains := map[string]*domain{}
+	for _, l := range literals {
+		if l.comparison == nil {
+			if l.source == "" {
+				continue
+			}
+			if negated, found := polarities[l.source]; found && negated != l.negated {
+				return true
+			}
+			polarities[l.source] = l.negated
+			continue
+		}
+		d, found := domains[l.comparison.field]
+		if !found {
+			d = &domain{}
+			domains[l.comparison.field] = d
+		}
+		d.add(l.comparison)
+	}
+	for _, d := range domains {
+		if d.empty() {
+			return true
+		}
+	}
+	return false
+}
+
+const notIn = "@not_in"
+
+var negatedOperators = map[string]string{
+	operators.Equals:        operators.NotEquals,
+	operators.NotEquals:     operators.Equals,
+	operators.Less:          operators.GreaterEquals,
+	operators.LessEquals:    operators.Greater,
+	operators.Greater:       operators.LessEquals,
+	operators.GreaterEquals: operators.Less,
+	operators.In:            notIn,
+	notIn:                   operators.In,
+}
+
+var flippedOperators = map[string]string{
+	operators.Equals:        operators.Equals,
+	operators.NotEquals:     operators.NotEquals,
+	operators.Less:          operators.Greater,
+	operators.LessEquals:    operators.GreaterEquals,
+	operators.Greater:       operators.Less,
+	operators.GreaterEquals: operators.LessEquals,
+}
+
+// comparison compares a field with literal values, e.g. `x > 1` or `x in [1, 2]`.
+type comparison struct {
+	operand  *exprpb.Expr
+	field    string
+	operator string
+	values   []ref.Val
+}
+
+func (c *comparison) negate() *comparison {
+	return &comparison{operand: c.operand, field: c.field, operator: negatedOperators[c.operator], values: c.values}
+}
+
+func parseComparison(checkedExpr *exprpb.CheckedExpr, expr *exprpb.Expr) *comparison {
+	if isField(expr) {
+		// a bool field is true.
+		if checkedExpr.GetTypeMap()[expr.GetId()].GetPrimitive() != exprpb.Type_BOOL {
+			return nil
+		}
+		field, err := parser.Unparse(expr, checkedExpr.GetSourceInfo())
+		if err != nil {
+			return nil
+		}
+		return &comparison{operand: expr, field: field, operator: operators.Equals, values: []ref.Val{types.True}}
+	}
+	c := expr.GetCallExpr()
+	if len(c.GetArgs()) != 2 {
+		return nil
+	}
+	lhs, rhs := c.GetArgs()[0], c.GetArgs()[1]
+	operator := c.GetFunction()
+	var values []ref.Val
+	switch {
+	case operator == operators.In:
+		for _, elem := range rhs.GetListExpr().GetElements() {
+			value, ok := literalValue(elem)
+			if !ok {
+				return nil
+			}
+			values = append(values, value)
+		}
+		if rhs.GetListExpr() == nil {
+			return nil
+		}
+	case flippedOperators[operator] != "":
+		if !isField(lhs) {
+			lhs, rhs, operator = rhs, lhs, flippedOperators[operator]
+		}
+		value, ok := literalValue(rhs)
+		if !ok {
+			return nil
+		}
+		values = []ref.Val{value}
+	default:
+		return nil
+	}
+	if !isField(lhs) {
+		return nil
+	}
+	field, err := parser.Unparse(lhs, checkedExpr.GetSourceInfo())
+	if err != nil {
+		return nil
+	}
+	return &comparison{operand: lhs, field: field, operator: operator, values: values}
+}
+
+// isNonNull reports whether the field is never NULL: the table variable, or the required fields of
+// the non-NULL records.
+func (an *analyzer) isNonNull(checkedExpr *exprpb.CheckedExpr, expr *exprpb.Expr) bool {
+	if expr.GetIdentExpr() != nil {
+		typeName := checkedExpr.GetTypeMap()[expr.GetId()].GetMessageType()
+		// the RECORD columns of RowTable are typed as the nested types of the row table, e.g.
+		// `users.addr`, while the table variables are typed as the tables.
+		i := strings.LastIndex(typeName, ".")
+		if i < 0 {
+			return typeName != ""
+		}
+		return an.nullability != nil && an.nullability.IsRequired(typeName[:i], typeName[i+1:])
+	}
+	sel := expr.GetSelectExpr()
+	if an.nullability == nil || !an.isNonNull(checkedExpr, sel.GetOperand()) {
+		return false
+	}
+	typeName := checkedExpr.GetTypeMap()[sel.GetOperand().GetId()].GetMessageType()
+	return typeName != "" && an.nullability.IsRequired(typeName, sel.GetField())
+}
+
+// isField reports whether the expression is an identifier or a field selection.
+func isField(expr *exprpb.Expr) bool {
+	switch {
+	case expr.GetIdentExpr() != nil:
+		return true
+	case expr.GetSelectExpr() != nil:
+		return !expr.GetSelectExpr().GetTestOnly() && isField(expr.GetSelectExpr().GetOperand())
+	}
+	return false
+}
+
+// literalValue returns the value of the literal, including timestamps, dates and datetimes
+// constructed from string literals, which are compared as timestamps.
+func literalValue(expr *exprpb.Expr) (ref.Val, bool) {
+	if c := expr.GetConstExpr(); c != nil {
+		switch c.ConstantKind.(type) {
+		case *exprpb.Constant_BoolValue:
+			return types.Bool(c.GetBoolValue()), true
+		case *exprpb.Constant_Int64Value:
+			return types.Int(c.GetInt64Value()), true
+		case *exprpb.Constant_Uint64Value:
+			return types.Uint(c.GetUint64Value()), true
+		case *exprpb.Constant_DoubleValue:
+			return types.Double(c.GetDoubleValue()), true
+		case *exprpb.Constant_StringValue:
+			return types.String(c.GetStringValue()), true
+		case *exprpb.Constant_BytesValue:
+			return types.Bytes(c.GetBytesValue()), true
+		}
+		return nil, false
+	}
+	call := expr.GetCallExpr()
+	if call.GetTarget() != nil || len(call.GetArgs()) != 1 || !isStringLiteral(call.GetArgs()[0]) {
+		return nil, false
+	}
+	s := call.GetArgs()[0].GetConstExpr().GetStringValue()
+	switch call.GetFunction() {
+	case overloads.TypeConvertTimestamp:
+		t, err := time.Parse(time.RFC3339Nano, s)
+		if err != nil {
+			return nil, false
+		}
+		return types.Timestamp{Time: t}, true
+	case "date":
+		d, err := civil.ParseDate(s)
+		if err != nil {
+			return nil, false
+		}
+		return types.Timestamp{Time: d.In(time.UTC)}, true
+	case "datetime":
+		dt, err := civil.ParseDateTime(s)
+		if err != nil {
+			return nil, false
+		}
+		return types.Timestamp{Time: dt.In(time.UTC)}, true
+	}
+	return nil, false
+}
+
+// compareValues compares the values of the same type, and returns false if they are incomparable.
+func compareValues(a, b ref.Val) (int, bool) {
+	if a.Type() != b.Type() {
+		return 0, false
+	}
+	comparer, ok := a.(traits.Comparer)
+	if !ok {
+		return 0, false
+	}
+	result, ok := comparer.Compare(b).(types.Int)
+	if !ok {
+		return 0, false
+	}
+	return int(result), true
+}
+
+// domain is the set of the values of a field satisfying comparisons.
+type domain struct {
+	lower, upper                   ref.Val
+	lowerInclusive, upperInclusive bool
+	// values restricts the domain to them if restricted is true.
+	values     []ref.Val
+	restricted bool
+	excluded   []ref.Val
+	// incomparable is true if the comparisons are of different types.
+	incomparable bool
+}
+
+func (d *domain) add(c *comparison) {
+	for _, value := range c.values {
+		if d.incomparable || !d.comparable(value) {
+			d.incomparable = true
+			return
+		}
+	}
+	if !d.restricted && len(c.values) > 0 && c.values[0].Type() == types.BoolType {
+		d.values, d.restricted = []ref.Val{types.True, types.False}, true
+	}
+	switch c.operator {
+	case operators.Equals:
+		d.restrict(c.values)
+	case operators.In:
+		d.restrict(c.values)
+	case operators.NotEquals, notIn:
+		d.excluded = append(d.excluded, c.values...)
+	case operators.Less, operators.LessEquals:
+		if cmp, _ := compareValues(c.values[0], orValue(d.upper, c.values[0])); d.upper == nil || cmp < 0 || cmp == 0 && d.upperInclusive {
+			d.upper, d.upperInclusive = c.values[0], c.operator == operators.LessEquals
+		}
+	case operators.Greater, operators.GreaterEquals:
+		if cmp, _ := compareValues(c.values[0], orValue(d.lower, c.values[0])); d.lower == nil || cmp > 0 || cmp == 0 && d.lowerInclusive {
+			d.lower, d.lowerInclusive = c.values[0], c.operator == operators.GreaterEquals
+		}
+	}
+}
+
+func orValue(value, defaultValue ref.Val) ref.Val {
+	if value == nil {
+		return defaultValue
+	}
+	return value
+}
+
+// comparable reports whether the value is comparable with the values of the domain.
+func (d *domain) comparable(value ref.Val) bool {
+	for _, v := range append(append([]ref.Val{d.lower, d.upper}, d.values...), d.excluded...) {
+		if v != nil && v.Type() != value.Type() {
+			return false
+		}
+	}
+	return true
+}
+
+func (d *domain) restrict(values []ref.Val) {
+	if !d.restricted {
+		d.values, d.restricted = values, true
+		return
+	}
+	var intersection []ref.Val
+	for _, v := range d.values {
+		if containsValue(values, v) {
+			intersection = append(intersection, v)
+		}
+	}
+	d.values = intersection
+}
+
+func containsValue(values []ref.Val, value ref.Val) bool {
+	for _, v := range values {
+		if cmp, ok := compareValues(v, value); ok && cmp == 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// contains reports whether the value satisfies the bounds and the exclusions of the domain.
+func (d *domain) contains(value ref.Val) bool {
+	if d.lower != nil {
+		if cmp, ok := compareValues(value, d.lower); ok && (cmp < 0 || cmp == 0 && !d.lowerInclusive) {
+			return false
+		}
+	}
+	if d.upper != nil {
+		if cmp, ok := compareValues(value, d.upper); ok && (cmp > 0 || cmp == 0 && !d.upperInclusive) {
+			return false
+		}
+	}
+	return !containsValue(d.excluded, value)
+}
+
+// maxEnumeration is the maximum number of integers enumerated to check their exclusion.
+const maxEnumeration = 64
+
+// empty reports whether no value is in the domain. It returns false when it cannot be proven.
+func (d *domain) empty() bool {
+	if d.incomparable {
+		return false
+	}
+	if d.restricted {
+		for _, v := range d.values {
+			if d.contains(v) {
+				return false
+			}
+		}
+		return true
+	}
+	if d.lower == nil || d.upper == nil {
+		return false
+	}
+	if lower, ok := d.lower.(types.Int); ok {
+		// enumerate the integers between the bounds.
+		upper := d.upper.(types.Int)
+		if !d.lowerInclusive {
+			lower++
+		}
+		if !d.upperInclusive {
+			upper--
+		}
+		if lower > upper {
+			return true
+		}
+		if upper-lower >= maxEnumeration {
+			return false
+		}
+		for i := lower; i <= upper; i++ {
+			if !containsValue(d.excluded, i) {
+				return false
+			}
+		}
+		return true
+	}
+	cmp, ok := compareValues(d.lower, d.upper)
+	if !ok {
+		return false
+	}
+	return cmp > 0 || cmp == 0 && (!d.lowerInclusive || !d.upperInclusive || containsValue(d.excluded, d.lower))
+}
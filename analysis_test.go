@@ -0,0 +1,426 @@
+package cel2sql_test
+
+import (
+	"testing"
+
+	"cloud.google.com/go/bigquery"
+	"github.com/google/cel-go/cel"
+	"github.com/google/cel-go/checker/decls"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/cockscomb/cel2sql"
+	"github.com/cockscomb/cel2sql/test"
+)
+
+func TestImplies(t *testing.T) {
+	env, err := cel2sql.NewEnv(
+		map[string]bigquery.Schema{
+			"wikipedia": test.NewWikipediaTableMetadata().Schema,
+		},
+		cel2sql.TableVariable("page", "wikipedia"),
+		cel2sql.Declarations(decls.NewVar("created_at", decls.Timestamp)),
+	)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name string
+		a    string
+		b    string
+		want bool
+	}{
+		{
+			name: "narrowerRange",
+			a:    `page.id > 10 && page.id < 20`,
+			b:    `page.id >= 5`,
+			want: true,
+		},
+		{
+			name: "widerRange",
+			a:    `page.id >= 5`,
+			b:    `page.id > 10`,
+			want: false,
+		},
+		{
+			name: "integerBounds",
+			a:    `page.id > 10`,
+			b:    `page.id >= 11`,
+			want: true,
+		},
+		{
+			name: "flipped",
+			a:    `10 < page.id`,
+			b:    `page.id > 5`,
+			want: true,
+		},
+		{
+			name: "timestampRange",
+			a:    `created_at >= timestamp("2021-06-01T00:00:00Z")`,
+			b:    `created_at > timestamp("2021-01-01T00:00:00Z")`,
+			want: true,
+		},
+		{
+			name: "timestampRangeNotImplied",
+			a:    `created_at >= timestamp("2021-01-01T00:00:00Z")`,
+			b:    `created_at > timestamp("2021-01-01T00:00:00Z")`,
+			want: false,
+		},
+		{
+			name: "inSubset",
+			a:    `page.language in ["en", "ja"]`,
+			b:    `page.language in ["en", "fr", "ja"]`,
+			want: true,
+		},
+		{
+			name: "inSuperset",
+			a:    `page.language in ["en", "fr", "ja"]`,
+			b:    `page.language in ["en", "ja"]`,
+			want: false,
+		},
+		{
+			name: "equalsIn",
+			a:    `page.language == "en"`,
+			b:    `page.language in ["en", "ja"]`,
+			want: true,
+		},
+		{
+			name: "inEquals",
+			a:    `page.language in ["en"]`,
+			b:    `page.language == "en"`,
+			want: true,
+		},
+		{
+			name: "inRange",
+			a:    `page.id in [3, 4, 5]`,
+			b:    `page.id > 2 && page.id != 6`,
+			want: true,
+		},
+		{
+			name: "disjunctions",
+			a:    `page.id == 1 || page.id == 2`,
+			b:    `page.id < 3`,
+			want: true,
+		},
+		{
+			name: "conjunct",
+			a:    `page.is_bot && page.id > 1`,
+			b:    `page.is_bot`,
+			want: true,
+		},
+		{
+			name: "opaque",
+			a:    `page.title.startsWith("a") && page.id > 1`,
+			b:    `page.title.startsWith("a")`,
+			want: true,
+		},
+		{
+			name: "opaqueNegated",
+			a:    `!page.title.startsWith("a")`,
+			b:    `page.title.startsWith("a")`,
+			want: false,
+		},
+		{
+			name: "unconstrained",
+			a:    `page.id > 1`,
+			b:    `page.title != "a" || page.title == "a"`,
+			want: false,
+		},
+		{
+			name: "unconstrainedDropped",
+			a:    `page.id > 10`,
+			b:    `page.title == "a" || page.id > 5`,
+			want: true,
+		},
+		{
+			name: "contradictoryPremise",
+			a:    `page.id > 1 && page.id < 1`,
+			b:    `page.title == "a"`,
+			want: true,
+		},
+		{
+			name: "differentFields",
+			a:    `page.id > 10`,
+			b:    `page.revision_id > 10`,
+			want: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, issues := env.Compile(tt.a)
+			require.Empty(t, issues)
+			b, issues := env.Compile(tt.b)
+			require.Empty(t, issues)
+
+			got, err := cel2sql.Implies(a, b)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestImplies_maxClauses(t *testing.T) {
+	env, err := cel2sql.NewEnv(
+		map[string]bigquery.Schema{
+			"wikipedia": test.NewWikipediaTableMetadata().Schema,
+		},
+		cel2sql.TableVariable("page", "wikipedia"),
+	)
+	require.NoError(t, err)
+
+	a, issues := env.Compile(`page.id == 1 || page.id == 2 || page.id == 3`)
+	require.Empty(t, issues)
+	b, issues := env.Compile(`page.id < 4`)
+	require.Empty(t, issues)
+
+	got, err := cel2sql.Implies(a, b)
+	require.NoError(t, err)
+	assert.True(t, got)
+
+	got, err = cel2sql.Implies(a, b, cel2sql.AnalysisMaxClauses(2))
+	require.NoError(t, err)
+	assert.False(t, got)
+}
+
+func TestIsContradiction(t *testing.T) {
+	env, err := cel2sql.NewEnv(
+		map[string]bigquery.Schema{
+			"wikipedia": test.NewWikipediaTableMetadata().Schema,
+		},
+		cel2sql.TableVariable("page", "wikipedia"),
+		cel2sql.Declarations(decls.NewVar("created_at", decls.Timestamp)),
+	)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name    string
+		source  string
+		want    bool
+		wantErr bool
+	}{
+		{
+			name:   "disjointRanges",
+			source: `page.id > 30 && page.id < 20`,
+			want:   true,
+		},
+		{
+			name:   "overlappingRanges",
+			source: `page.id > 20 && page.id < 30`,
+			want:   false,
+		},
+		{
+			name:   "exclusiveBounds",
+			source: `page.id > 20 && page.id < 21`,
+			want:   true,
+		},
+		{
+			name:   "excludedPoints",
+			source: `page.id >= 1 && page.id <= 2 && page.id != 1 && page.id != 2`,
+			want:   true,
+		},
+		{
+			name:   "differentEquals",
+			source: `page.title == "a" && page.title == "b"`,
+			want:   true,
+		},
+		{
+			name:   "equalsNotEquals",
+			source: `page.title == "a" && page.title != "a"`,
+			want:   true,
+		},
+		{
+			name:   "disjointIn",
+			source: `page.language in ["en", "ja"] && page.language in ["fr"]`,
+			want:   true,
+		},
+		{
+			name:   "inOutOfRange",
+			source: `page.id in [1, 2] && page.id > 2`,
+			want:   true,
+		},
+		{
+			name:   "bool",
+			source: `page.is_bot && !page.is_bot`,
+			want:   true,
+		},
+		{
+			name:   "boolEquals",
+			source: `page.is_bot == true && page.is_bot != true`,
+			want:   true,
+		},
+		{
+			name:   "opaque",
+			source: `page.title.startsWith("a") && !page.title.startsWith("a")`,
+			want:   true,
+		},
+		{
+			name:   "timestamps",
+			source: `created_at < timestamp("2021-01-01T00:00:00Z") && created_at > timestamp("2021-06-01T00:00:00Z")`,
+			want:   true,
+		},
+		{
+			name:   "everyDisjunct",
+			source: `(page.id > 30 || page.is_bot) && page.id < 20 && !page.is_bot`,
+			want:   true,
+		},
+		{
+			name:   "someDisjunct",
+			source: `(page.id > 30 || page.is_bot) && page.id < 20`,
+			want:   false,
+		},
+		{
+			name:   "false",
+			source: `false`,
+			want:   true,
+		},
+		{
+			name:    "notBool",
+			source:  `page.id`,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ast, issues := env.Compile(tt.source)
+			require.Empty(t, issues)
+
+			got, err := cel2sql.IsContradiction(ast)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestIsTautology(t *testing.T) {
+	env, err := cel2sql.NewEnv(
+		map[string]bigquery.Schema{
+			"wikipedia": test.NewWikipediaTableMetadata().Schema,
+		},
+		cel2sql.TableVariable("page", "wikipedia"),
+	)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name    string
+		source  string
+		opts    []cel2sql.AnalysisOption
+		want    bool
+		wantErr bool
+	}{
+		{
+			name:   "equalsOrNotEquals",
+			source: `page.title == "a" || page.title != "a"`,
+			opts:   []cel2sql.AnalysisOption{cel2sql.FieldNullability(env.TypeProvider())},
+			want:   true,
+		},
+		{
+			name:   "nullableWithoutProvider",
+			source: `page.title == "a" || page.title != "a"`,
+			want:   false,
+		},
+		{
+			name:   "nullable",
+			source: `page.id > 1 || page.id <= 1`,
+			opts:   []cel2sql.AnalysisOption{cel2sql.FieldNullability(env.TypeProvider())},
+			want:   false,
+		},
+		{
+			name:   "coveringRanges",
+			source: `page.num_characters > 10 || page.num_characters < 20`,
+			opts:   []cel2sql.AnalysisOption{cel2sql.FieldNullability(env.TypeProvider())},
+			want:   true,
+		},
+		{
+			name:   "gap",
+			source: `page.num_characters > 10 || page.num_characters < 10`,
+			opts:   []cel2sql.AnalysisOption{cel2sql.FieldNullability(env.TypeProvider())},
+			want:   false,
+		},
+		{
+			name:   "everyConjunct",
+			source: `(page.wp_namespace >= 0 || page.wp_namespace < 0) && (page.language != "en" || page.language == "en")`,
+			opts:   []cel2sql.AnalysisOption{cel2sql.FieldNullability(env.TypeProvider())},
+			want:   true,
+		},
+		{
+			name:   "true",
+			source: `true`,
+			want:   true,
+		},
+		{
+			name:    "notBool",
+			source:  `page.id`,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ast, issues := env.Compile(tt.source)
+			require.Empty(t, issues)
+
+			got, err := cel2sql.IsTautology(ast, tt.opts...)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestIsTautology_rowTableRecord(t *testing.T) {
+	schemas := map[string]bigquery.Schema{
+		"users": {
+			{Name: "addr", Type: bigquery.RecordFieldType, Schema: bigquery.Schema{
+				{Name: "city", Type: bigquery.StringFieldType, Required: true},
+			}},
+			{Name: "home", Type: bigquery.RecordFieldType, Required: true, Schema: bigquery.Schema{
+				{Name: "city", Type: bigquery.StringFieldType, Required: true},
+			}},
+		},
+	}
+	rowEnv, err := cel2sql.NewEnv(schemas, cel2sql.RowTable("users"))
+	require.NoError(t, err)
+	env, err := cel2sql.NewEnv(schemas, cel2sql.TableVariable("u", "users"))
+	require.NoError(t, err)
+
+	tests := []struct {
+		name   string
+		env    *cel.Env
+		source string
+		want   bool
+	}{
+		{
+			name:   "nullableRecordColumn",
+			env:    rowEnv,
+			source: `addr.city == "x" || addr.city != "x"`,
+			want:   false,
+		},
+		{
+			name:   "requiredRecordColumn",
+			env:    rowEnv,
+			source: `home.city == "x" || home.city != "x"`,
+			want:   true,
+		},
+		{
+			name:   "nullableRecordField",
+			env:    env,
+			source: `u.addr.city == "x" || u.addr.city != "x"`,
+			want:   false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ast, issues := tt.env.Compile(tt.source)
+			require.Empty(t, issues)
+
+			got, err := cel2sql.IsTautology(ast, cel2sql.FieldNullability(tt.env.TypeProvider()))
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
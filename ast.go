@@ -0,0 +1,158 @@
+package cel2sql
+
+import (
+	"github.com/google/cel-go/checker/decls"
+	"github.com/google/cel-go/common/operators"
+	"github.com/google/cel-go/common/overloads"
+	exprpb "google.golang.org/genproto/googleapis/api/expr/v1alpha1"
+)
+
+// walkExpr calls the visitor for the expression and its sub-expressions in pre-order.
+func walkExpr(expr *exprpb.Expr, visitor func(*exprpb.Expr)) {
+	if expr == nil {
+		return
+	}
+	visitor(expr)
+	for _, child := range childExprs(expr) {
+		walkExpr(child, visitor)
+	}
+}
+
+// childExprs returns the direct sub-expressions of the expression.
+func childExprs(expr *exprpb.Expr) []*exprpb.Expr {
+	var children []*exprpb.Expr
+	switch kind := expr.ExprKind.(type) {
+	case *exprpb.Expr_SelectExpr:
+		children = append(children, kind.SelectExpr.GetOperand())
+	case *exprpb.Expr_CallExpr:
+		children = append(children, kind.CallExpr.GetTarget())
+		children = append(children, kind.CallExpr.GetArgs()...)
+	case *exprpb.Expr_ListExpr:
+		children = append(children, kind.ListExpr.GetElements()...)
+	case *exprpb.Expr_StructExpr:
+		for _, entry := range kind.StructExpr.GetEntries() {
+			children = append(children, entry.GetMapKey(), entry.GetValue())
+		}
+	case *exprpb.Expr_ComprehensionExpr:
+		c := kind.ComprehensionExpr
+		children = append(children, c.GetIterRange(), c.GetAccuInit(), c.GetLoopCondition(), c.GetLoopStep(), c.GetResult())
+	}
+	return children
+}
+
+// referencedVariables returns the names of the variables referenced by the checked expression.
+func referencedVariables(checkedExpr *exprpb.CheckedExpr) map[string]bool {
+	variables := map[string]bool{}
+	for _, reference := range checkedExpr.GetReferenceMap() {
+		if reference.GetName() != "" && len(reference.GetOverloadId()) == 0 && reference.GetValue() == nil {
+			variables[reference.GetName()] = true
+		}
+	}
+	return variables
+}
+
+// conjuncts flattens the operands of the top-level && operators of the expression.
+func conjuncts(expr *exprpb.Expr) []*exprpb.Expr {
+	if c := expr.GetCallExpr(); c.GetFunction() == operators.LogicalAnd {
+		var exprs []*exprpb.Expr
+		for _, arg := range c.GetArgs() {
+			exprs = append(exprs, conjuncts(arg)...)
+		}
+		return exprs
+	}
+	return []*exprpb.Expr{expr}
+}
+
+// maxExprID returns the largest ID of the expression and its sub-expressions.
+func maxExprID(expr *exprpb.Expr) int64 {
+	var id int64
+	walkExpr(expr, func(e *exprpb.Expr) {
+		if e.GetId() > id {
+			id = e.GetId()
+		}
+	})
+	return id
+}
+
+// exprFactory creates the expressions added to a checked expression, registering their types and
+// references in copies of the maps of the original.
+type exprFactory struct {
+	checkedExpr  *exprpb.CheckedExpr
+	typeMap      map[int64]*exprpb.Type
+	referenceMap map[int64]*exprpb.Reference
+	nextID       int64
+}
+
+func newExprFactory(checkedExpr *exprpb.CheckedExpr) *exprFactory {
+	f := &exprFactory{
+		checkedExpr:  checkedExpr,
+		typeMap:      make(map[int64]*exprpb.Type, len(checkedExpr.GetTypeMap())),
+		referenceMap: make(map[int64]*exprpb.Reference, len(checkedExpr.GetReferenceMap())),
+		nextID:       maxExprID(checkedExpr.GetExpr()),
+	}
+	for id, typ := range checkedExpr.GetTypeMap() {
+		f.typeMap[id] = typ
+	}
+	for id, reference := range checkedExpr.GetReferenceMap() {
+		f.referenceMap[id] = reference
+	}
+	return f
+}
+
+func (f *exprFactory) newID(typ *exprpb.Type) int64 {
+	f.nextID++
+	f.typeMap[f.nextID] = typ
+	return f.nextID
+}
+
+func (f *exprFactory) newCall(function string, overloadID string, typ *exprpb.Type, args ...*exprpb.Expr) *exprpb.Expr {
+	id := f.newID(typ)
+	f.referenceMap[id] = &exprpb.Reference{OverloadId: []string{overloadID}}
+	return &exprpb.Expr{Id: id, ExprKind: &exprpb.Expr_CallExpr{CallExpr: &exprpb.Expr_Call{
+		Function: function,
+		Args:     args,
+	}}}
+}
+
+func (f *exprFactory) newBool(value bool) *exprpb.Expr {
+	return newBoolLiteral(f.newID(decls.Bool), value)
+}
+
+func (f *exprFactory) newNot(expr *exprpb.Expr) *exprpb.Expr {
+	return f.newCall(operators.LogicalNot, overloads.LogicalNot, decls.Bool, expr)
+}
+
+// newJunction returns the conjunction or disjunction of the expressions, which is `true` or
+// `false` respectively when there are no expressions.
+func (f *exprFactory) newJunction(function string, exprs []*exprpb.Expr) *exprpb.Expr {
+	overloadID := overloads.LogicalAnd
+	if function == operators.LogicalOr {
+		overloadID = overloads.LogicalOr
+	}
+	if len(exprs) == 0 {
+		return f.newBool(function == operators.LogicalAnd)
+	}
+	expr := exprs[0]
+	for _, e := range exprs[1:] {
+		expr = f.newCall(function, overloadID, decls.Bool, expr, e)
+	}
+	return expr
+}
+
+// checked returns the checked expression of the expression created by the factory.
+func (f *exprFactory) checked(expr *exprpb.Expr) *exprpb.CheckedExpr {
+	return &exprpb.CheckedExpr{
+		ReferenceMap: f.referenceMap,
+		TypeMap:      f.typeMap,
+		SourceInfo:   f.checkedExpr.GetSourceInfo(),
+		ExprVersion:  f.checkedExpr.GetExprVersion(),
+		Expr:         expr,
+	}
+}
+
+// conjoin returns the checked conjunction of the sub-expressions of the checked expression. The
+// conjunction of no expressions is `true`.
+func conjoin(checkedExpr *exprpb.CheckedExpr, exprs []*exprpb.Expr) *exprpb.CheckedExpr {
+	f := newExprFactory(checkedExpr)
+	return f.checked(f.newJunction(operators.LogicalAnd, exprs))
+}
@@ -0,0 +1,47 @@
+package bq
+
+// NameMapper maps between CEL field names and physical column names. The type name is the CEL
+// type name of the record containing the field, e.g. `users` or `users.address`.
+type NameMapper interface {
+	// ColumnName returns the physical column name of the CEL field name.
+	ColumnName(typeName string, fieldName string) string
+	// FieldName returns the CEL field name of the physical column name.
+	FieldName(typeName string, columnName string) string
+}
+
+// NameMap declares CEL field names by type name and CEL field name. Fields which are not in the
+// map keep their column names.
+type NameMap map[string]map[string]string
+
+func (m NameMap) ColumnName(typeName string, fieldName string) string {
+	if columnName, found := m[typeName][fieldName]; found {
+		return columnName
+	}
+	return fieldName
+}
+
+func (m NameMap) FieldName(typeName string, columnName string) string {
+	for fieldName, c := range m[typeName] {
+		if c == columnName {
+			return fieldName
+		}
+	}
+	return columnName
+}
+
+// NameMapperFuncs adapts a pair of functions to NameMapper.
+type NameMapperFuncs struct {
+	ToColumnName func(typeName string, fieldName string) string
+	ToFieldName  func(typeName string, columnName string) string
+}
+
+func (f NameMapperFuncs) ColumnName(typeName string, fieldName string) string {
+	return f.ToColumnName(typeName, fieldName)
+}
+
+func (f NameMapperFuncs) FieldName(typeName string, columnName string) string {
+	return f.ToFieldName(typeName, columnName)
+}
+
+var _ NameMapper = NameMap{}
+var _ NameMapper = NameMapperFuncs{}
@@ -1,6 +1,7 @@
 package bq
 
 import (
+	"sort"
 	"strings"
 
 	"cloud.google.com/go/bigquery"
@@ -13,11 +14,55 @@ import (
 )
 
 type typeProvider struct {
-	schemas map[string]bigquery.Schema
+	schemas           map[string]bigquery.Schema
+	tables            map[string]*bigquery.TableMetadata
+	names             NameMapper
+	allowedPolicyTags map[string]bool
 }
 
-func NewTypeProvider(schemas map[string]bigquery.Schema) *typeProvider {
-	return &typeProvider{schemas: schemas}
+// Option configures the type provider.
+type Option func(*typeProvider)
+
+// ColumnNames exposes the columns by the CEL field names of the mapper instead of their
+// physical names.
+func ColumnNames(names NameMapper) Option {
+	return func(p *typeProvider) {
+		p.names = names
+	}
+}
+
+// AllowedPolicyTags restricts the columns having policy tags other than the allowed ones, e.g.
+// `projects/p/locations/us/taxonomies/1/policyTags/2`, as the caller is not a Fine-Grained Reader
+// of them. The columns are not restricted without this option.
+func AllowedPolicyTags(policyTags ...string) Option {
+	return func(p *typeProvider) {
+		if p.allowedPolicyTags == nil {
+			p.allowedPolicyTags = map[string]bool{}
+		}
+		for _, policyTag := range policyTags {
+			p.allowedPolicyTags[policyTag] = true
+		}
+	}
+}
+
+func NewTypeProvider(schemas map[string]bigquery.Schema, opts ...Option) *typeProvider {
+	p := &typeProvider{schemas: schemas}
+	for _, opt := range opts {
+		opt(p)
+	}
+	return p
+}
+
+// NewTableTypeProvider returns the type provider of the tables, which also knows their
+// partitioning.
+func NewTableTypeProvider(tables map[string]*bigquery.TableMetadata, opts ...Option) *typeProvider {
+	schemas := make(map[string]bigquery.Schema, len(tables))
+	for name, table := range tables {
+		schemas[name] = table.Schema
+	}
+	p := NewTypeProvider(schemas, opts...)
+	p.tables = tables
+	return p
 }
 
 func (p *typeProvider) EnumValue(enumName string) ref.Val {
@@ -28,24 +73,35 @@ func (p *typeProvider) FindIdent(identName string) (ref.Val, bool) {
 	return nil, false
 }
 
+// fieldName returns the CEL field name of the column.
+func (p *typeProvider) fieldName(typeName string, columnName string) string {
+	if p.names == nil {
+		return columnName
+	}
+	return p.names.FieldName(typeName, columnName)
+}
+
+func (p *typeProvider) findField(typeName string, schema bigquery.Schema, fieldName string) *bigquery.FieldSchema {
+	for _, fieldSchema := range schema {
+		if p.fieldName(typeName, fieldSchema.Name) == fieldName {
+			return fieldSchema
+		}
+	}
+	return nil
+}
+
 func (p *typeProvider) findSchema(typeName string) (bigquery.Schema, bool) {
 	typeNames := strings.Split(typeName, ".")
 	schema, found := p.schemas[typeNames[0]]
 	if !found {
 		return nil, false
 	}
-	for _, tn := range typeNames[1:] {
-		var s *bigquery.Schema
-		for _, fieldSchema := range schema {
-			if fieldSchema.Name == tn {
-				s = &fieldSchema.Schema
-				break
-			}
-		}
-		if s == nil {
+	for i, tn := range typeNames[1:] {
+		field := p.findField(strings.Join(typeNames[:i+1], "."), schema, tn)
+		if field == nil {
 			return nil, false
 		}
-		schema = *s
+		schema = field.Schema
 	}
 	return schema, true
 }
@@ -63,14 +119,13 @@ func (p *typeProvider) FindFieldType(messageType string, fieldName string) (*ref
 	if !found {
 		return nil, false
 	}
-	var field *bigquery.FieldSchema
-	for _, fieldSchema := range schema {
-		if fieldSchema.Name == fieldName {
-			field = fieldSchema
-			break
-		}
-	}
+	field := p.findField(messageType, schema, fieldName)
 	if field == nil {
+		for _, column := range p.pseudoColumns(messageType) {
+			if column == fieldName {
+				return &ref.FieldType{Type: pseudoColumnTypes[column]}, true
+			}
+		}
 		return nil, false
 	}
 	var typ *exprpb.Type
@@ -104,6 +159,106 @@ func (p *typeProvider) FindFieldType(messageType string, fieldName string) (*ref
 	}, true
 }
 
+// TypeNames returns the names of the table types and their nested RECORD types.
+func (p *typeProvider) TypeNames() []string {
+	var typeNames []string
+	var collect func(typeName string, schema bigquery.Schema)
+	collect = func(typeName string, schema bigquery.Schema) {
+		typeNames = append(typeNames, typeName)
+		for _, fieldSchema := range schema {
+			if fieldSchema.Type == bigquery.RecordFieldType {
+				collect(strings.Join([]string{typeName, p.fieldName(typeName, fieldSchema.Name)}, "."), fieldSchema.Schema)
+			}
+		}
+	}
+	for name, schema := range p.schemas {
+		collect(name, schema)
+	}
+	sort.Strings(typeNames)
+	return typeNames
+}
+
+// FieldNames returns the CEL field names of the type.
+func (p *typeProvider) FieldNames(typeName string) ([]string, bool) {
+	schema, found := p.findSchema(typeName)
+	if !found {
+		return nil, false
+	}
+	fieldNames := make([]string, len(schema))
+	for i, fieldSchema := range schema {
+		fieldNames[i] = p.fieldName(typeName, fieldSchema.Name)
+	}
+	return append(fieldNames, p.pseudoColumns(typeName)...), true
+}
+
+// IsRequired reports whether the field of the type is a REQUIRED column, which is never NULL.
+func (p *typeProvider) IsRequired(typeName string, fieldName string) bool {
+	schema, found := p.findSchema(typeName)
+	if !found {
+		return false
+	}
+	field := p.findField(typeName, schema, fieldName)
+	return field != nil && field.Required
+}
+
+// RestrictedPolicyTags returns the policy tags of the field which are not allowed by
+// AllowedPolicyTags, including those of the nested fields of a RECORD field, as reading the
+// record reads all of them. It returns nil if the field is accessible.
+func (p *typeProvider) RestrictedPolicyTags(typeName string, fieldName string) []string {
+	if p.allowedPolicyTags == nil {
+		return nil
+	}
+	schema, found := p.findSchema(typeName)
+	if !found {
+		return nil
+	}
+	field := p.findField(typeName, schema, fieldName)
+	if field == nil {
+		return nil
+	}
+	var restricted []string
+	var collect func(field *bigquery.FieldSchema)
+	collect = func(field *bigquery.FieldSchema) {
+		if field.PolicyTags != nil {
+			for _, policyTag := range field.PolicyTags.Names {
+				if !p.allowedPolicyTags[policyTag] {
+					restricted = append(restricted, policyTag)
+				}
+			}
+		}
+		for _, nested := range field.Schema {
+			collect(nested)
+		}
+	}
+	collect(field)
+	return restricted
+}
+
+// PartitionField returns the CEL field name of the column partitioning the table by time or
+// range, which is _PARTITIONTIME for ingestion-time partitioned tables. It returns false if the
+// table is not partitioned.
+func (p *typeProvider) PartitionField(typeName string) (string, bool) {
+	table, found := p.tables[typeName]
+	if !found {
+		return "", false
+	}
+	switch {
+	case table.TimePartitioning != nil && table.TimePartitioning.Field == "":
+		return PartitionTime, true
+	case table.TimePartitioning != nil:
+		return p.fieldName(typeName, table.TimePartitioning.Field), true
+	case table.RangePartitioning != nil && table.RangePartitioning.Field != "":
+		return p.fieldName(typeName, table.RangePartitioning.Field), true
+	}
+	return "", false
+}
+
+// RequiresPartitionFilter reports whether queries of the table must filter its partitions.
+func (p *typeProvider) RequiresPartitionFilter(typeName string) bool {
+	table, found := p.tables[typeName]
+	return found && table.RequirePartitionFilter
+}
+
 func (p *typeProvider) NewValue(typeName string, fields map[string]ref.Val) ref.Val {
 	return types.NewErr("unknown type '%s'", typeName)
 }
@@ -10,6 +10,7 @@ import (
 	exprpb "google.golang.org/genproto/googleapis/api/expr/v1alpha1"
 
 	"github.com/cockscomb/cel2sql/bq"
+	"github.com/cockscomb/cel2sql/sqltypes"
 	"github.com/cockscomb/cel2sql/test"
 )
 
@@ -214,3 +215,338 @@ func Test_typeProvider_FindFieldType(t *testing.T) {
 		})
 	}
 }
+
+func Test_typeProvider_TypeNames(t *testing.T) {
+	typeProvider := bq.NewTypeProvider(map[string]bigquery.Schema{
+		"trigrams":  test.NewTrigramsTableMetadata().Schema,
+		"wikipedia": test.NewWikipediaTableMetadata().Schema,
+	})
+	assert.Equal(t, []string{
+		"trigrams",
+		"trigrams.cell",
+		"trigrams.cell.sample",
+		"wikipedia",
+	}, typeProvider.TypeNames())
+}
+
+func Test_typeProvider_ColumnNames(t *testing.T) {
+	typeProvider := bq.NewTypeProvider(map[string]bigquery.Schema{
+		"trigrams": test.NewTrigramsTableMetadata().Schema,
+	}, bq.ColumnNames(bq.NameMap{
+		"trigrams":       {"cells": "cell"},
+		"trigrams.cells": {"pageCount": "page_count", "samples": "sample"},
+	}))
+
+	type args struct {
+		messageType string
+		fieldName   string
+	}
+	tests := []struct {
+		name      string
+		args      args
+		want      *ref.FieldType
+		wantFound bool
+	}{
+		{
+			name: "trigrams.cells",
+			args: args{
+				messageType: "trigrams",
+				fieldName:   "cells",
+			},
+			want: &ref.FieldType{
+				Type: decls.NewListType(decls.NewObjectType("trigrams.cells")),
+			},
+			wantFound: true,
+		},
+		{
+			name: "trigrams.cells.pageCount",
+			args: args{
+				messageType: "trigrams.cells",
+				fieldName:   "pageCount",
+			},
+			want: &ref.FieldType{
+				Type: decls.Int,
+			},
+			wantFound: true,
+		},
+		{
+			name: "trigrams.cells.samples.id",
+			args: args{
+				messageType: "trigrams.cells.samples",
+				fieldName:   "id",
+			},
+			want: &ref.FieldType{
+				Type: decls.String,
+			},
+			wantFound: true,
+		},
+		{
+			name: "trigrams.cell",
+			args: args{
+				messageType: "trigrams",
+				fieldName:   "cell",
+			},
+			want:      nil,
+			wantFound: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, gotFound := typeProvider.FindFieldType(tt.args.messageType, tt.args.fieldName)
+			if assert.Equal(t, tt.wantFound, gotFound) {
+				assert.Equal(t, tt.want, got)
+			}
+		})
+	}
+	assert.Equal(t, []string{
+		"trigrams",
+		"trigrams.cells",
+		"trigrams.cells.samples",
+	}, typeProvider.TypeNames())
+}
+
+func Test_typeProvider_IsRequired(t *testing.T) {
+	typeProvider := bq.NewTypeProvider(map[string]bigquery.Schema{
+		"wikipedia": test.NewWikipediaTableMetadata().Schema,
+	})
+
+	tests := []struct {
+		name      string
+		typeName  string
+		fieldName string
+		want      bool
+	}{
+		{
+			name:      "required",
+			typeName:  "wikipedia",
+			fieldName: "title",
+			want:      true,
+		},
+		{
+			name:      "nullable",
+			typeName:  "wikipedia",
+			fieldName: "id",
+			want:      false,
+		},
+		{
+			name:      "unknownField",
+			typeName:  "wikipedia",
+			fieldName: "unknown",
+			want:      false,
+		},
+		{
+			name:      "unknownType",
+			typeName:  "unknown",
+			fieldName: "title",
+			want:      false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, typeProvider.IsRequired(tt.typeName, tt.fieldName))
+		})
+	}
+}
+
+func Test_typeProvider_PartitionField(t *testing.T) {
+	typeProvider := bq.NewTableTypeProvider(map[string]*bigquery.TableMetadata{
+		"events": {
+			Schema: bigquery.Schema{
+				{Name: "created_at", Type: bigquery.TimestampFieldType},
+			},
+			TimePartitioning:       &bigquery.TimePartitioning{Field: "created_at"},
+			RequirePartitionFilter: true,
+		},
+		"shards": {
+			Schema: bigquery.Schema{
+				{Name: "shard_id", Type: bigquery.IntegerFieldType},
+			},
+			RangePartitioning: &bigquery.RangePartitioning{Field: "shard_id"},
+		},
+		"wikipedia": test.NewWikipediaTableMetadata(),
+	}, bq.ColumnNames(bq.NameMap{
+		"shards": {"shardID": "shard_id"},
+	}))
+
+	tests := []struct {
+		name         string
+		typeName     string
+		wantField    string
+		wantFound    bool
+		wantRequired bool
+	}{
+		{
+			name:         "time",
+			typeName:     "events",
+			wantField:    "created_at",
+			wantFound:    true,
+			wantRequired: true,
+		},
+		{
+			name:      "range",
+			typeName:  "shards",
+			wantField: "shardID",
+			wantFound: true,
+		},
+		{
+			name:     "notPartitioned",
+			typeName: "wikipedia",
+		},
+		{
+			name:     "unknown",
+			typeName: "unknown",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			field, found := typeProvider.PartitionField(tt.typeName)
+			assert.Equal(t, tt.wantField, field)
+			assert.Equal(t, tt.wantFound, found)
+			assert.Equal(t, tt.wantRequired, typeProvider.RequiresPartitionFilter(tt.typeName))
+		})
+	}
+}
+
+func Test_typeProvider_PseudoColumns(t *testing.T) {
+	schema := bigquery.Schema{
+		{Name: "name", Type: bigquery.StringFieldType},
+	}
+	typeProvider := bq.NewTableTypeProvider(map[string]*bigquery.TableMetadata{
+		"daily":    {Schema: schema, TimePartitioning: &bigquery.TimePartitioning{Type: bigquery.DayPartitioningType}},
+		"hourly":   {Schema: schema, TimePartitioning: &bigquery.TimePartitioning{Type: bigquery.HourPartitioningType}},
+		"events_*": {Schema: schema},
+		"events":   {Schema: schema},
+	})
+
+	tests := []struct {
+		name      string
+		typeName  string
+		fieldName string
+		want      *ref.FieldType
+		wantFound bool
+	}{
+		{
+			name:      "partitionTime",
+			typeName:  "daily",
+			fieldName: bq.PartitionTime,
+			want:      &ref.FieldType{Type: decls.Timestamp},
+			wantFound: true,
+		},
+		{
+			name:      "partitionDate",
+			typeName:  "daily",
+			fieldName: bq.PartitionDate,
+			want:      &ref.FieldType{Type: sqltypes.Date},
+			wantFound: true,
+		},
+		{
+			name:      "hourlyPartitionDate",
+			typeName:  "hourly",
+			fieldName: bq.PartitionDate,
+			wantFound: false,
+		},
+		{
+			name:      "tableSuffix",
+			typeName:  "events_*",
+			fieldName: bq.TableSuffix,
+			want:      &ref.FieldType{Type: decls.String},
+			wantFound: true,
+		},
+		{
+			name:      "notWildcard",
+			typeName:  "events",
+			fieldName: bq.TableSuffix,
+			wantFound: false,
+		},
+		{
+			name:      "notPartitioned",
+			typeName:  "events",
+			fieldName: bq.PartitionTime,
+			wantFound: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, found := typeProvider.FindFieldType(tt.typeName, tt.fieldName)
+			assert.Equal(t, tt.wantFound, found)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+
+	fieldNames, found := typeProvider.FieldNames("daily")
+	assert.True(t, found)
+	assert.Equal(t, []string{"name", bq.PartitionTime, bq.PartitionDate}, fieldNames)
+	field, found := typeProvider.PartitionField("daily")
+	assert.True(t, found)
+	assert.Equal(t, bq.PartitionTime, field)
+}
+
+func Test_typeProvider_RestrictedPolicyTags(t *testing.T) {
+	const (
+		piiTag       = "projects/p/locations/us/taxonomies/1/policyTags/pii"
+		financialTag = "projects/p/locations/us/taxonomies/1/policyTags/financial"
+	)
+	schemas := map[string]bigquery.Schema{
+		"customers": {
+			{Name: "name", Type: bigquery.StringFieldType},
+			{Name: "email", Type: bigquery.StringFieldType, PolicyTags: &bigquery.PolicyTagList{Names: []string{piiTag}}},
+			{Name: "billing", Type: bigquery.RecordFieldType, Schema: bigquery.Schema{
+				{Name: "plan", Type: bigquery.StringFieldType},
+				{Name: "card", Type: bigquery.StringFieldType, PolicyTags: &bigquery.PolicyTagList{Names: []string{financialTag}}},
+			}},
+		},
+	}
+
+	tests := []struct {
+		name      string
+		opts      []bq.Option
+		typeName  string
+		fieldName string
+		want      []string
+	}{
+		{
+			name:      "restricted",
+			opts:      []bq.Option{bq.AllowedPolicyTags(financialTag)},
+			typeName:  "customers",
+			fieldName: "email",
+			want:      []string{piiTag},
+		},
+		{
+			name:      "allowed",
+			opts:      []bq.Option{bq.AllowedPolicyTags(piiTag)},
+			typeName:  "customers",
+			fieldName: "email",
+		},
+		{
+			name:      "untagged",
+			opts:      []bq.Option{bq.AllowedPolicyTags()},
+			typeName:  "customers",
+			fieldName: "name",
+		},
+		{
+			name:      "record",
+			opts:      []bq.Option{bq.AllowedPolicyTags()},
+			typeName:  "customers",
+			fieldName: "billing",
+			want:      []string{financialTag},
+		},
+		{
+			name:      "nested",
+			opts:      []bq.Option{bq.AllowedPolicyTags()},
+			typeName:  "customers.billing",
+			fieldName: "card",
+			want:      []string{financialTag},
+		},
+		{
+			name:      "notEnforced",
+			typeName:  "customers",
+			fieldName: "email",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			typeProvider := bq.NewTypeProvider(schemas, tt.opts...)
+			assert.Equal(t, tt.want, typeProvider.RestrictedPolicyTags(tt.typeName, tt.fieldName))
+		})
+	}
+}
@@ -0,0 +1,58 @@
+package bq
+
+import (
+	"strings"
+
+	"cloud.google.com/go/bigquery"
+	"github.com/google/cel-go/checker/decls"
+	exprpb "google.golang.org/genproto/googleapis/api/expr/v1alpha1"
+
+	"github.com/cockscomb/cel2sql/sqltypes"
+)
+
+// The pseudo-columns of BigQuery, which are not in the schemas of the tables.
+const (
+	// PartitionTime is the partition of the rows of an ingestion-time partitioned table.
+	PartitionTime = "_PARTITIONTIME"
+	// PartitionDate is the partition of the rows of a daily ingestion-time partitioned table.
+	PartitionDate = "_PARTITIONDATE"
+	// TableSuffix is the suffix of the table matched by a wildcard table, e.g. `events_*`.
+	TableSuffix = "_TABLE_SUFFIX"
+)
+
+var pseudoColumnTypes = map[string]*exprpb.Type{
+	PartitionTime: decls.Timestamp,
+	PartitionDate: sqltypes.Date,
+	TableSuffix:   decls.String,
+}
+
+// IsPseudoColumn reports whether the name is of a pseudo-column, which is referenced without
+// qualification.
+func IsPseudoColumn(name string) bool {
+	_, found := pseudoColumnTypes[name]
+	return found
+}
+
+// IsWildcardTable reports whether the table name ends with `*`, e.g. `events_*`.
+func IsWildcardTable(typeName string) bool {
+	return strings.HasSuffix(typeName, "*")
+}
+
+// pseudoColumns returns the pseudo-columns of the table: _PARTITIONTIME and _PARTITIONDATE of
+// ingestion-time partitioned tables, and _TABLE_SUFFIX of wildcard tables.
+func (p *typeProvider) pseudoColumns(typeName string) []string {
+	if _, found := p.schemas[typeName]; !found {
+		return nil
+	}
+	var columns []string
+	if table, found := p.tables[typeName]; found && table.TimePartitioning != nil && table.TimePartitioning.Field == "" {
+		columns = append(columns, PartitionTime)
+		if t := table.TimePartitioning.Type; t == "" || t == bigquery.DayPartitioningType {
+			columns = append(columns, PartitionDate)
+		}
+	}
+	if IsWildcardTable(typeName) {
+		columns = append(columns, TableSuffix)
+	}
+	return columns
+}
@@ -10,32 +10,94 @@ import (
 	"github.com/google/cel-go/cel"
 	"github.com/google/cel-go/common/operators"
 	"github.com/google/cel-go/common/overloads"
+	"github.com/google/cel-go/common/types/ref"
 	exprpb "google.golang.org/genproto/googleapis/api/expr/v1alpha1"
+
+	"github.com/cockscomb/cel2sql/bq"
+	"github.com/cockscomb/cel2sql/composite"
 )
 
 // Implementations based on `google/cel-go`'s unparser
 // https://github.com/google/cel-go/blob/master/parser/unparser.go
 
-func Convert(ast *cel.Ast) (string, error) {
-	checkedExpr, err := cel.AstToCheckedExpr(ast)
+func Convert(ast *cel.Ast, opts ...ConvertOption) (string, error) {
+	filter, err := ConvertFilter(ast, opts...)
 	if err != nil {
 		return "", err
 	}
-	un := &converter{
-		typeMap: checkedExpr.TypeMap,
+	return filter.SQL, nil
+}
+
+func newConverter(checkedExpr *exprpb.CheckedExpr, opts []ConvertOption) *converter {
+	con := &converter{
+		typeMap:    checkedExpr.TypeMap,
+		sourceInfo: checkedExpr.SourceInfo,
+	}
+	for _, opt := range opts {
+		opt(con)
 	}
-	if err := un.visit(checkedExpr.Expr); err != nil {
+	return con
+}
+
+// convert converts the expr, which is a part of the checked expression of the converter.
+func (con *converter) convert(expr *exprpb.Expr) (string, error) {
+	con.str.Reset()
+	if err := con.check(expr); err != nil {
 		return "", err
 	}
-	return un.str.String(), nil
+	if err := con.visit(expr); err != nil {
+		con.locate(err)
+		return "", err
+	}
+	if err := con.checkSQLLength(); err != nil {
+		return "", err
+	}
+	return con.str.String(), nil
+}
+
+// check validates the expression against the limits, the policy tags and the allowed operators
+// without converting it.
+func (con *converter) check(expr *exprpb.Expr) error {
+	for _, pass := range []func(*exprpb.Expr) error{con.checkLimits, con.checkPolicyTags, con.checkAllowedOperators} {
+		if err := pass(expr); err != nil {
+			con.locate(err)
+			return err
+		}
+	}
+	return nil
 }
 
 type converter struct {
-	str     strings.Builder
-	typeMap map[int64]*exprpb.Type
+	str        strings.Builder
+	typeMap    map[int64]*exprpb.Type
+	sourceInfo *exprpb.SourceInfo
+
+	rowColumns   *rowColumns
+	names        bq.NameMapper
+	tableSources map[string]string
+	parameters   map[string]bool
+	rowPolicies  []rowPolicy
+	fieldTypes   ref.TypeProvider
+
+	dialect         Dialect
+	sessionSettings map[string]string
+	policyTagger    composite.PolicyTagger
+
+	allowedOperators map[string]map[string]bool
+
+	limits limits
+	depth  int
 }
 
-func (con *converter) visit(expr *exprpb.Expr) error {
+func (con *converter) visit(expr *exprpb.Expr) (err error) {
+	defer func() {
+		con.depth--
+		// the innermost expression is the offending one unless the error locates another.
+		locateExpr(err, expr)
+	}()
+	if err := con.enterVisit(expr); err != nil {
+		return err
+	}
 	switch expr.ExprKind.(type) {
 	case *exprpb.Expr_CallExpr:
 		return con.visitCall(expr)
@@ -53,7 +115,7 @@ func (con *converter) visit(expr *exprpb.Expr) error {
 	case *exprpb.Expr_StructExpr:
 		return con.visitStruct(expr)
 	}
-	return fmt.Errorf("unsupported expr: %v", expr)
+	return &UnsupportedExprError{Reason: fmt.Sprintf("%T", expr.ExprKind)}
 }
 
 func (con *converter) visitCall(expr *exprpb.Expr) error {
@@ -111,8 +173,9 @@ func (con *converter) visitCallBinary(expr *exprpb.Expr) error {
 	rhsParen := isComplexOperatorWithRespectTo(fun, rhs)
 	lhsType := con.getType(lhs)
 	rhsType := con.getType(rhs)
-	if (isTimestampRelatedType(lhsType) && isDurationRelatedType(rhsType)) ||
-		(isTimestampRelatedType(rhsType) && isDurationRelatedType(lhsType)) {
+	// PostgreSQL adds and subtracts intervals by the operators.
+	if con.dialect == BigQuery && ((isTimestampRelatedType(lhsType) && isDurationRelatedType(rhsType)) ||
+		(isTimestampRelatedType(rhsType) && isDurationRelatedType(lhsType))) {
 		return con.callTimestampOperation(fun, lhs, rhs)
 	}
 	if !rhsParen && isLeftRecursive(fun) {
@@ -137,7 +200,15 @@ func (con *converter) visitCallBinary(expr *exprpb.Expr) error {
 	} else if op, found := operators.FindReverseBinaryOperator(fun); found {
 		operator = op
 	} else {
-		return fmt.Errorf("cannot unmangle operator: %s", fun)
+		return con.unsupportedFunctionError(fun)
+	}
+	if con.dialect == PostgreSQL && fun == operators.In && isListType(rhsType) {
+		con.str.WriteString(" = ANY(")
+		if err := con.visit(rhs); err != nil {
+			return err
+		}
+		con.str.WriteString(")")
+		return nil
 	}
 	con.str.WriteString(" ")
 	con.str.WriteString(operator)
@@ -235,7 +306,7 @@ func (con *converter) callTimestampOperation(fun string, lhs *exprpb.Expr, rhs *
 			sqlFun = "TIMESTAMP_SUB"
 		}
 	default:
-		return fmt.Errorf("unsupported operation (%s)", fun)
+		return con.unsupportedFunctionError(fun)
 	}
 	con.str.WriteString(sqlFun)
 	con.str.WriteString("(")
@@ -253,6 +324,9 @@ func (con *converter) callTimestampOperation(fun string, lhs *exprpb.Expr, rhs *
 func (con *converter) visitCallConditional(expr *exprpb.Expr) error {
 	c := expr.GetCallExpr()
 	args := c.GetArgs()
+	if con.dialect == PostgreSQL {
+		return con.visitCallCase(args)
+	}
 	con.str.WriteString("IF(")
 	if err := con.visit(args[0]); err != nil {
 		return err
@@ -269,6 +343,24 @@ func (con *converter) visitCallConditional(expr *exprpb.Expr) error {
 	return nil
 }
 
+// visitCallCase renders the conditional as the CASE expression of PostgreSQL.
+func (con *converter) visitCallCase(args []*exprpb.Expr) error {
+	con.str.WriteString("CASE WHEN ")
+	if err := con.visit(args[0]); err != nil {
+		return err
+	}
+	con.str.WriteString(" THEN ")
+	if err := con.visit(args[1]); err != nil {
+		return err
+	}
+	con.str.WriteString(" ELSE ")
+	if err := con.visit(args[2]); err != nil {
+		return err
+	}
+	con.str.WriteString(" END")
+	return nil
+}
+
 var standardSQLFunctions = map[string]string{
 	operators.Modulo:     "MOD",
 	overloads.StartsWith: "STARTS_WITH",
@@ -277,7 +369,11 @@ var standardSQLFunctions = map[string]string{
 }
 
 func (con *converter) callContains(target *exprpb.Expr, args []*exprpb.Expr) error {
-	con.str.WriteString("INSTR(")
+	if con.dialect == PostgreSQL {
+		con.str.WriteString("STRPOS(")
+	} else {
+		con.str.WriteString("INSTR(")
+	}
 	if target != nil {
 		nested := isBinaryOrTernaryOperator(target)
 		err := con.visitMaybeNested(target, nested)
@@ -301,26 +397,21 @@ func (con *converter) callContains(target *exprpb.Expr, args []*exprpb.Expr) err
 
 func (con *converter) callDuration(target *exprpb.Expr, args []*exprpb.Expr) error {
 	if len(args) != 1 {
-		return fmt.Errorf("arguments must be single")
+		return &UnsupportedExprError{Reason: "arguments of duration must be single"}
 	}
 	arg := args[0]
-	var durationString string
-	switch arg.ExprKind.(type) {
-	case *exprpb.Expr_ConstExpr:
-		switch arg.GetConstExpr().ConstantKind.(type) {
-		case *exprpb.Constant_StringValue:
-			durationString = arg.GetConstExpr().GetStringValue()
-		default:
-			return fmt.Errorf("unsupported constant kind %t", arg.GetConstExpr().ConstantKind)
-		}
-	default:
-		return fmt.Errorf("unsupported kind %t", arg.ExprKind)
+	if !isStringLiteral(arg) {
+		return &UnsupportedExprError{Location: Location{ExprID: arg.GetId()}, Reason: "argument of duration must be a string literal"}
 	}
-	d, err := time.ParseDuration(durationString)
+	d, err := time.ParseDuration(arg.GetConstExpr().GetStringValue())
 	if err != nil {
-		return err
+		return &UnsupportedExprError{Location: Location{ExprID: arg.GetId()}, Reason: err.Error()}
 	}
 	con.str.WriteString("INTERVAL ")
+	if con.dialect == PostgreSQL {
+		con.str.WriteString("'")
+		defer con.str.WriteString("'")
+	}
 	switch d {
 	case d.Round(time.Hour):
 		con.str.WriteString(strconv.FormatFloat(d.Hours(), 'f', 0, 64))
@@ -342,6 +433,9 @@ func (con *converter) callDuration(target *exprpb.Expr, args []*exprpb.Expr) err
 }
 
 func (con *converter) callInterval(target *exprpb.Expr, args []*exprpb.Expr) error {
+	if con.dialect == PostgreSQL {
+		return con.unsupportedFunctionError("interval")
+	}
 	con.str.WriteString("INTERVAL ")
 	if err := con.visit(args[0]); err != nil {
 		return err
@@ -353,6 +447,9 @@ func (con *converter) callInterval(target *exprpb.Expr, args []*exprpb.Expr) err
 }
 
 func (con *converter) callExtractFromTimestamp(function string, target *exprpb.Expr, args []*exprpb.Expr) error {
+	if con.dialect == PostgreSQL {
+		return con.unsupportedFunctionError(function)
+	}
 	con.str.WriteString("EXTRACT(")
 	switch function {
 	case overloads.TimeGetFullYear:
@@ -395,6 +492,9 @@ func (con *converter) callExtractFromTimestamp(function string, target *exprpb.E
 
 func (con *converter) callCasting(function string, target *exprpb.Expr, args []*exprpb.Expr) error {
 	arg := args[0]
+	if con.dialect == PostgreSQL {
+		return con.callPostgresCasting(function, arg)
+	}
 	if function == overloads.TypeConvertInt && isTimestampType(con.getType(arg)) {
 		con.str.WriteString("UNIX_SECONDS(")
 		if err := con.visit(arg); err != nil {
@@ -426,6 +526,119 @@ func (con *converter) callCasting(function string, target *exprpb.Expr, args []*
 	return nil
 }
 
+func (con *converter) callPostgresCasting(function string, arg *exprpb.Expr) error {
+	if function == overloads.TypeConvertInt && isTimestampType(con.getType(arg)) {
+		con.str.WriteString("CAST(EXTRACT(EPOCH FROM ")
+		if err := con.visit(arg); err != nil {
+			return err
+		}
+		con.str.WriteString(") AS BIGINT)")
+		return nil
+	}
+	if function == overloads.TypeConvertTimestamp && con.getType(arg).GetPrimitive() == exprpb.Type_INT64 {
+		con.str.WriteString("TO_TIMESTAMP(")
+		if err := con.visit(arg); err != nil {
+			return err
+		}
+		con.str.WriteString(")")
+		return nil
+	}
+	var castType string
+	switch function {
+	case overloads.TypeConvertBool:
+		castType = "BOOLEAN"
+	case overloads.TypeConvertBytes:
+		castType = "BYTEA"
+	case overloads.TypeConvertDouble:
+		castType = "DOUBLE PRECISION"
+	case overloads.TypeConvertInt, overloads.TypeConvertUint:
+		castType = "BIGINT"
+	case overloads.TypeConvertString:
+		castType = "TEXT"
+	case overloads.TypeConvertTimestamp:
+		castType = "TIMESTAMPTZ"
+	}
+	con.str.WriteString("CAST(")
+	if err := con.visit(arg); err != nil {
+		return err
+	}
+	con.str.WriteString(" AS ")
+	con.str.WriteString(castType)
+	con.str.WriteString(")")
+	return nil
+}
+
+// postgresFunctions are the functions of CEL rendered as the functions of PostgreSQL.
+var postgresFunctions = map[string]string{
+	operators.Modulo:     "MOD",
+	overloads.StartsWith: "STARTS_WITH",
+}
+
+// visitPostgresCallFunc renders the call of the function in PostgreSQL, which supports only the
+// standard functions of CEL.
+func (con *converter) visitPostgresCallFunc(fun string, target *exprpb.Expr, args []*exprpb.Expr) error {
+	if target != nil {
+		args = append([]*exprpb.Expr{target}, args...)
+	}
+	switch fun {
+	case overloads.Matches:
+		return con.writePostgresOperator(args[0], "~", args[1])
+	case overloads.EndsWith:
+		// RIGHT(s, LENGTH(suffix)) = suffix
+		con.str.WriteString("RIGHT(")
+		if err := con.visit(args[0]); err != nil {
+			return err
+		}
+		con.str.WriteString(", LENGTH(")
+		if err := con.visit(args[1]); err != nil {
+			return err
+		}
+		con.str.WriteString(")) = ")
+		return con.visitMaybeNested(args[1], isComplexOperatorWithRespectTo(operators.Equals, args[1]))
+	case overloads.TypeConvertTimestamp:
+		return con.callPostgresCasting(fun, args[0])
+	case overloads.Size:
+		argType := con.getType(args[0])
+		switch {
+		case argType.GetPrimitive() == exprpb.Type_STRING, argType.GetPrimitive() == exprpb.Type_BYTES:
+			return con.writeFunction("LENGTH", args)
+		case isListType(argType):
+			return con.writeFunction("CARDINALITY", args)
+		}
+		return &UnsupportedTypeError{Location: Location{ExprID: args[0].GetId()}, Type: cel.FormatType(argType)}
+	}
+	sqlFun, found := postgresFunctions[fun]
+	if !found {
+		return con.unsupportedFunctionError(fun)
+	}
+	return con.writeFunction(sqlFun, args)
+}
+
+func (con *converter) writePostgresOperator(lhs *exprpb.Expr, operator string, rhs *exprpb.Expr) error {
+	if err := con.visitMaybeNested(lhs, isComplexOperator(lhs)); err != nil {
+		return err
+	}
+	con.str.WriteString(" ")
+	con.str.WriteString(operator)
+	con.str.WriteString(" ")
+	return con.visitMaybeNested(rhs, isComplexOperator(rhs))
+}
+
+func (con *converter) writeFunction(sqlFun string, args []*exprpb.Expr) error {
+	con.str.WriteString(sqlFun)
+	con.str.WriteString("(")
+	for i, arg := range args {
+		if err := con.visit(arg); err != nil {
+			return err
+		}
+		if i < len(args)-1 {
+			con.str.WriteString(", ")
+		}
+	}
+	con.str.WriteString(")")
+	return nil
+}
+
 func (con *converter) visitCallFunc(expr *exprpb.Expr) error {
 	c := expr.GetCallExpr()
 	fun := c.GetFunction()
@@ -457,6 +670,9 @@ func (con *converter) visitCallFunc(expr *exprpb.Expr) error {
 		overloads.TypeConvertUint:
 		return con.callCasting(fun, target, args)
 	}
+	if con.dialect == PostgreSQL {
+		return con.visitPostgresCallFunc(fun, target, args)
+	}
 	sqlFun, ok := standardSQLFunctions[fun]
 	if !ok {
 		if fun == overloads.Size {
@@ -469,7 +685,7 @@ func (con *converter) visitCallFunc(expr *exprpb.Expr) error {
 			case isListType(argType):
 				sqlFun = "ARRAY_LENGTH"
 			default:
-				return fmt.Errorf("unsupported type: %v", argType)
+				return &UnsupportedTypeError{Location: Location{ExprID: args[0].GetId()}, Type: cel.FormatType(argType)}
 			}
 		} else {
 			sqlFun = strings.ToUpper(fun)
@@ -517,9 +733,8 @@ func (con *converter) visitCallMapIndex(expr *exprpb.Expr) error {
 	if err != nil {
 		return err
 	}
-	con.str.WriteString(".`")
-	con.str.WriteString(fieldName)
-	con.str.WriteString("`")
+	con.str.WriteString(".")
+	con.str.WriteString(con.quoteIdentifier(con.columnName(con.mapTypeName(m), fieldName)))
 	return nil
 }
 
@@ -528,11 +743,24 @@ func (con *converter) visitCallListIndex(expr *exprpb.Expr) error {
 	args := c.GetArgs()
 	l := args[0]
 	nested := isBinaryOrTernaryOperator(l)
+	if con.dialect == PostgreSQL {
+		// PostgreSQL subscripts only column references without parentheses, e.g. not ARRAY[1, 2][1].
+		nested = !isColumnReference(l)
+	}
 	if err := con.visitMaybeNested(l, nested); err != nil {
 		return err
 	}
-	con.str.WriteString("[OFFSET(")
 	index := args[1]
+	if con.dialect == PostgreSQL {
+		// arrays of PostgreSQL are one-based.
+		con.str.WriteString("[")
+		if err := con.visitMaybeNested(index, isComplexOperatorWithRespectTo(operators.Add, index)); err != nil {
+			return err
+		}
+		con.str.WriteString(" + 1]")
+		return nil
+	}
+	con.str.WriteString("[OFFSET(")
 	if err := con.visit(index); err != nil {
 		return err
 	}
@@ -540,6 +768,23 @@ func (con *converter) visitCallListIndex(expr *exprpb.Expr) error {
 	return nil
 }
 
+// isColumnReference reports whether the expression is an identifier or a field selected from it.
+func isColumnReference(expr *exprpb.Expr) bool {
+	for {
+		switch expr.ExprKind.(type) {
+		case *exprpb.Expr_IdentExpr:
+			return true
+		case *exprpb.Expr_SelectExpr:
+			if expr.GetSelectExpr().GetTestOnly() {
+				return false
+			}
+			expr = expr.GetSelectExpr().GetOperand()
+		default:
+			return false
+		}
+	}
+}
+
 var standardSQLUnaryOperators = map[string]string{
 	operators.LogicalNot: "NOT ",
 }
@@ -548,13 +793,24 @@ func (con *converter) visitCallUnary(expr *exprpb.Expr) error {
 	c := expr.GetCallExpr()
 	fun := c.GetFunction()
 	args := c.GetArgs()
+	// render the negation of has() as IS NULL.
+	if sel := args[0].GetSelectExpr(); fun == operators.LogicalNot && sel.GetTestOnly() {
+		if con.isRepeatedField(sel) {
+			return con.writeHasRepeated(sel, true)
+		}
+		if err := con.visitSelectField(sel); err != nil {
+			return err
+		}
+		con.str.WriteString(" IS NULL")
+		return nil
+	}
 	var operator string
 	if op, found := standardSQLUnaryOperators[fun]; found {
 		operator = op
 	} else if op, found := operators.FindReverse(fun); found {
 		operator = op
 	} else {
-		return fmt.Errorf("cannot unmangle operator: %s", fun)
+		return con.unsupportedFunctionError(fun)
 	}
 	con.str.WriteString(operator)
 	nested := isComplexOperator(args[0])
@@ -564,7 +820,7 @@ func (con *converter) visitCallUnary(expr *exprpb.Expr) error {
 func (con *converter) visitComprehension(expr *exprpb.Expr) error {
 	// TODO: introduce a macro expansion map between the top-level comprehension id and the
 	// function call that the macro replaces.
-	return fmt.Errorf("unimplemented : %v", expr)
+	return &UnsupportedExprError{Reason: "comprehension"}
 }
 
 func (con *converter) visitConst(expr *exprpb.Expr) error {
@@ -578,6 +834,10 @@ func (con *converter) visitConst(expr *exprpb.Expr) error {
 		}
 	case *exprpb.Constant_BytesValue:
 		b := c.GetBytesValue()
+		if con.dialect == PostgreSQL {
+			con.str.WriteString(quotePostgresBytes(b))
+			break
+		}
 		con.str.WriteString(`b"`)
 		con.str.WriteString(bytesToOctets(b))
 		con.str.WriteString(`"`)
@@ -590,26 +850,58 @@ func (con *converter) visitConst(expr *exprpb.Expr) error {
 	case *exprpb.Constant_NullValue:
 		con.str.WriteString("NULL")
 	case *exprpb.Constant_StringValue:
+		if con.dialect == PostgreSQL {
+			con.str.WriteString(quotePostgresString(c.GetStringValue()))
+			break
+		}
 		con.str.WriteString(strconv.Quote(c.GetStringValue()))
 	case *exprpb.Constant_Uint64Value:
 		ui := strconv.FormatUint(c.GetUint64Value(), 10)
 		con.str.WriteString(ui)
 	default:
-		return fmt.Errorf("unimplemented : %v", expr)
+		return &UnsupportedExprError{Reason: fmt.Sprintf("constant %T", c.ConstantKind)}
 	}
 	return nil
 }
 
 func (con *converter) visitIdent(expr *exprpb.Expr) error {
-	con.str.WriteString("`")
-	con.str.WriteString(expr.GetIdentExpr().GetName())
-	con.str.WriteString("`")
+	name := expr.GetIdentExpr().GetName()
+	if con.parameters[name] {
+		con.str.WriteString(con.parameter(name))
+		return nil
+	}
+	if source, found := con.tableSources[name]; found {
+		if source == "" {
+			return &UnsupportedExprError{Reason: fmt.Sprintf("table variable \"%s\" without qualifier cannot be referenced by itself", name)}
+		}
+		con.str.WriteString(con.quoteIdentifier(tableAlias(source)))
+		return nil
+	}
+	if con.rowColumns.isColumn(name) && bq.IsPseudoColumn(name) {
+		con.str.WriteString(name)
+		return nil
+	}
+	if setting, found := con.sessionSettings[name]; found {
+		return con.writeSessionSetting(setting, con.getType(expr))
+	}
+	if con.rowColumns.isColumn(name) && con.rowColumns.qualifier != "" {
+		con.str.WriteString(con.quoteIdentifier(con.rowColumns.qualifier))
+		con.str.WriteString(".")
+	}
+	if con.rowColumns.isColumn(name) {
+		con.str.WriteString(con.quoteIdentifier(con.columnName(con.rowColumns.table, name)))
+	} else {
+		con.str.WriteString(con.quoteIdentifier(name))
+	}
 	return nil
 }
 
 func (con *converter) visitList(expr *exprpb.Expr) error {
 	l := expr.GetListExpr()
 	elems := l.GetElements()
+	if con.dialect == PostgreSQL {
+		con.str.WriteString("ARRAY")
+	}
 	con.str.WriteString("[")
 	for i, elem := range elems {
 		err := con.visit(elem)
@@ -626,21 +918,74 @@ func (con *converter) visitList(expr *exprpb.Expr) error {
 
 func (con *converter) visitSelect(expr *exprpb.Expr) error {
 	sel := expr.GetSelectExpr()
+	// has() of a repeated field tests whether it is non-empty.
+	if sel.GetTestOnly() && con.isRepeatedField(sel) {
+		return con.writeHasRepeated(sel, false)
+	}
+	if err := con.visitSelectField(sel); err != nil {
+		return err
+	}
 	// handle the case when the select expression was generated by the has() macro.
 	if sel.GetTestOnly() {
-		con.str.WriteString("has(")
+		con.str.WriteString(" IS NOT NULL")
 	}
-	nested := !sel.GetTestOnly() && isBinaryOrTernaryOperator(sel.GetOperand())
-	err := con.visitMaybeNested(sel.GetOperand(), nested)
-	if err != nil {
+	return nil
+}
+
+// isRepeatedField reports whether the selected field of a message is a list, as far as the
+// provider of FieldTypes or RowColumns knows.
+func (con *converter) isRepeatedField(sel *exprpb.Expr_Select) bool {
+	provider := con.fieldTypes
+	if provider == nil && con.rowColumns != nil {
+		provider = con.rowColumns.provider
+	}
+	messageType := con.getType(sel.GetOperand()).GetMessageType()
+	if provider == nil || messageType == "" {
+		return false
+	}
+	fieldType, found := provider.FindFieldType(messageType, sel.GetField())
+	return found && isListType(fieldType.Type)
+}
+
+// writeHasRepeated writes has() of a repeated field, or its negation, as the test of the length of
+// the array, e.g. ARRAY_LENGTH(`cell`) > 0. NULL arrays of PostgreSQL are empty.
+func (con *converter) writeHasRepeated(sel *exprpb.Expr_Select, negated bool) error {
+	if con.dialect == PostgreSQL {
+		con.str.WriteString("COALESCE(CARDINALITY(")
+	} else {
+		con.str.WriteString("ARRAY_LENGTH(")
+	}
+	if err := con.visitSelectField(sel); err != nil {
 		return err
 	}
-	con.str.WriteString(".`")
-	con.str.WriteString(sel.GetField())
-	con.str.WriteString("`")
-	if sel.GetTestOnly() {
+	if con.dialect == PostgreSQL {
+		con.str.WriteString("), 0)")
+	} else {
 		con.str.WriteString(")")
 	}
+	if negated {
+		con.str.WriteString(" = 0")
+	} else {
+		con.str.WriteString(" > 0")
+	}
+	return nil
+}
+
+func (con *converter) visitSelectField(sel *exprpb.Expr_Select) error {
+	// BigQuery requires pseudo-columns, e.g. _PARTITIONTIME, to be unqualified.
+	if bq.IsPseudoColumn(sel.GetField()) && con.getType(sel.GetOperand()).GetMessageType() != "" {
+		con.str.WriteString(sel.GetField())
+		return nil
+	}
+	if !con.isUnqualifiedTable(sel.GetOperand()) {
+		nested := !sel.GetTestOnly() && isBinaryOrTernaryOperator(sel.GetOperand())
+		err := con.visitMaybeNested(sel.GetOperand(), nested)
+		if err != nil {
+			return err
+		}
+		con.str.WriteString(".")
+	}
+	con.str.WriteString(con.quoteIdentifier(con.columnName(con.getType(sel.GetOperand()).GetMessageType(), sel.GetField())))
 	return nil
 }
 
@@ -679,6 +1024,9 @@ func (con *converter) visitStructMsg(expr *exprpb.Expr) error {
 func (con *converter) visitStructMap(expr *exprpb.Expr) error {
 	m := expr.GetStructExpr()
 	entries := m.GetEntries()
+	if con.dialect == PostgreSQL {
+		return &UnsupportedExprError{Reason: fmt.Sprintf("map literal in %s", con.dialect)}
+	}
 	con.str.WriteString("STRUCT(")
 	for i, entry := range entries {
 		v := entry.GetValue()
@@ -717,6 +1065,47 @@ func (con *converter) getType(node *exprpb.Expr) *exprpb.Type {
 	return con.typeMap[node.GetId()]
 }
 
+// isUnqualifiedTable indicates whether the expr is a table variable whose columns are not qualified.
+func (con *converter) isUnqualifiedTable(expr *exprpb.Expr) bool {
+	if expr.GetIdentExpr() == nil {
+		return false
+	}
+	source, found := con.tableSources[expr.GetIdentExpr().GetName()]
+	return found && source == ""
+}
+
+// tableAlias returns the alias of the table source, which is the last component of a qualified
+// table name, as BigQuery aliases a table by it implicitly.
+func tableAlias(source string) string {
+	return source[strings.LastIndex(source, ".")+1:]
+}
+
+// columnName returns the physical column name of the field of the type.
+func (con *converter) columnName(typeName string, fieldName string) string {
+	if con.names == nil || typeName == "" {
+		return fieldName
+	}
+	return con.names.ColumnName(typeName, fieldName)
+}
+
+// mapTypeName returns the name by which the fields of a map are mapped. A map held in a field
+// is named like a RECORD type, i.e. the type name and the field name joined by a dot.
+func (con *converter) mapTypeName(m *exprpb.Expr) string {
+	switch m.ExprKind.(type) {
+	case *exprpb.Expr_SelectExpr:
+		typeName := con.getType(m.GetSelectExpr().GetOperand()).GetMessageType()
+		if typeName != "" {
+			return typeName + "." + m.GetSelectExpr().GetField()
+		}
+	case *exprpb.Expr_IdentExpr:
+		name := m.GetIdentExpr().GetName()
+		if con.rowColumns.isColumn(name) {
+			return con.rowColumns.table + "." + name
+		}
+	}
+	return ""
+}
+
 func isMapType(typ *exprpb.Type) bool {
 	_, ok := typ.TypeKind.(*exprpb.Type_MapType_)
 	return ok
@@ -828,17 +1217,18 @@ var fieldNameRegexp = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]{0,127}$`)
 
 func validateFieldName(name string) error {
 	if !fieldNameRegexp.MatchString(name) {
-		return fmt.Errorf("invalid field name \"%s\"", name)
+		return &InvalidFieldNameError{Name: name}
 	}
 	return nil
 }
 
 func extractFieldName(node *exprpb.Expr) (string, error) {
 	if !isStringLiteral(node) {
-		return "", fmt.Errorf("unsupported type: %v", node)
+		return "", &UnsupportedExprError{Location: Location{ExprID: node.GetId()}, Reason: "field name must be a string literal"}
 	}
 	fieldName := node.GetConstExpr().GetStringValue()
 	if err := validateFieldName(fieldName); err != nil {
+		locateExpr(err, node)
 		return "", err
 	}
 	return fieldName, nil
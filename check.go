@@ -0,0 +1,87 @@
+package cel2sql
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/google/cel-go/cel"
+	exprpb "google.golang.org/genproto/googleapis/api/expr/v1alpha1"
+)
+
+// CheckConstraint is a rule which every row of a table must satisfy, e.g.
+// `size(user.name) > 0 && user.age >= 0`.
+type CheckConstraint struct {
+	// Name is the name of the constraint.
+	Name string
+	// Table is the qualified name of the table.
+	Table string
+	// Variable is the table variable of the rule, whose fields are the columns of the table.
+	Variable string
+	// Rule is the predicate over the row.
+	Rule *cel.Ast
+}
+
+// CheckConstraintDDL returns the `ALTER TABLE ... ADD CONSTRAINT ... CHECK` statement of the
+// constraint in PostgreSQL. The rule must not reference other variables, call functions other
+// than the standard functions of CEL, or use comprehensions.
+//
+// As with CHECK constraints, a row for which the rule is NULL, e.g. because of a NULL column,
+// satisfies the constraint.
+func CheckConstraintDDL(constraint *CheckConstraint, opts ...ConvertOption) (string, error) {
+	if err := validateCheckConstraint(constraint); err != nil {
+		return "", err
+	}
+	opts = append(append([]ConvertOption{}, opts...), SQLDialect(PostgreSQL))
+	rule, err := convertPolicyPredicate(constraint.Rule, constraint.Variable, isPostgresPolicyOverload, opts)
+	if err != nil {
+		return "", fmt.Errorf("check constraint \"%s\": %w", constraint.Name, err)
+	}
+	con := newConverter(&exprpb.CheckedExpr{}, opts)
+	var b strings.Builder
+	b.WriteString("ALTER TABLE ")
+	b.WriteString(con.quoteQualifiedName(constraint.Table))
+	b.WriteString(" ADD CONSTRAINT ")
+	b.WriteString(con.quoteIdentifier(constraint.Name))
+	b.WriteString(" CHECK (")
+	b.WriteString(rule)
+	b.WriteString(")")
+	return b.String(), nil
+}
+
+// CheckAssertion returns the `ASSERT` statement of BigQuery which fails when a row of the table
+// violates the constraint, described by the name of the constraint. The rule must not reference
+// other variables, call functions other than those of CEL and sqltypes, or use comprehensions.
+//
+// The rows for which the rule is NULL satisfy the assertion as well as CheckConstraintDDL.
+func CheckAssertion(constraint *CheckConstraint, opts ...ConvertOption) (string, error) {
+	if err := validateCheckConstraint(constraint); err != nil {
+		return "", err
+	}
+	opts = append(append([]ConvertOption{}, opts...), SQLDialect(BigQuery))
+	rule, err := convertPolicyPredicate(constraint.Rule, constraint.Variable, func(overloadID string) bool {
+		return sqlOverloads[overloadID]
+	}, opts)
+	if err != nil {
+		return "", fmt.Errorf("check constraint \"%s\": %w", constraint.Name, err)
+	}
+	con := newConverter(&exprpb.CheckedExpr{}, opts)
+	var b strings.Builder
+	b.WriteString("ASSERT NOT EXISTS(SELECT * FROM ")
+	b.WriteString(con.quoteQualifiedName(constraint.Table))
+	b.WriteString(" WHERE NOT (")
+	b.WriteString(rule)
+	b.WriteString(")) AS ")
+	b.WriteString(strconv.Quote(constraint.Name))
+	return b.String(), nil
+}
+
+func validateCheckConstraint(constraint *CheckConstraint) error {
+	if !policyNameRegexp.MatchString(constraint.Name) {
+		return fmt.Errorf("invalid check constraint name \"%s\"", constraint.Name)
+	}
+	if constraint.Table == "" {
+		return fmt.Errorf("table of check constraint \"%s\" is empty", constraint.Name)
+	}
+	return nil
+}
@@ -0,0 +1,175 @@
+package cel2sql_test
+
+import (
+	"testing"
+
+	"cloud.google.com/go/bigquery"
+	"github.com/google/cel-go/cel"
+	"github.com/google/cel-go/checker/decls"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/cockscomb/cel2sql"
+	"github.com/cockscomb/cel2sql/test"
+)
+
+func newCheckConstraintEnv(t *testing.T) func(source string) *cel.Ast {
+	env, err := cel2sql.NewEnv(
+		map[string]bigquery.Schema{
+			"wikipedia": test.NewWikipediaTableMetadata().Schema,
+		},
+		cel2sql.TableVariable("page", "wikipedia"),
+		cel2sql.Declarations(decls.NewVar("language", decls.String)),
+	)
+	require.NoError(t, err)
+	return func(source string) *cel.Ast {
+		ast, issues := env.Compile(source)
+		require.Empty(t, issues)
+		return ast
+	}
+}
+
+func TestCheckConstraintDDL(t *testing.T) {
+	compile := newCheckConstraintEnv(t)
+
+	tests := []struct {
+		name       string
+		constraint *cel2sql.CheckConstraint
+		want       string
+		wantErr    bool
+	}{
+		{
+			name: "rule",
+			constraint: &cel2sql.CheckConstraint{
+				Name:     "valid_page",
+				Table:    "public.wikipedia",
+				Variable: "page",
+				Rule:     compile(`size(page.title) > 0 && page.num_characters >= 0`),
+			},
+			want: `ALTER TABLE "public"."wikipedia" ADD CONSTRAINT "valid_page" CHECK (LENGTH("title") > 0 AND "num_characters" >= 0)`,
+		},
+		{
+			name: "disjunction",
+			constraint: &cel2sql.CheckConstraint{
+				Name:     "language",
+				Table:    "wikipedia",
+				Variable: "page",
+				Rule:     compile(`page.language in ["en", "ja"] || page.is_bot`),
+			},
+			want: `ALTER TABLE "wikipedia" ADD CONSTRAINT "language" CHECK ("language" = ANY(ARRAY['en', 'ja']) OR "is_bot")`,
+		},
+		{
+			name: "otherVariable",
+			constraint: &cel2sql.CheckConstraint{
+				Name:     "language",
+				Table:    "wikipedia",
+				Variable: "page",
+				Rule:     compile(`page.language == language`),
+			},
+			wantErr: true,
+		},
+		{
+			name: "sqltypes",
+			constraint: &cel2sql.CheckConstraint{
+				Name:     "timestamp",
+				Table:    "wikipedia",
+				Variable: "page",
+				Rule:     compile(`page.timestamp < int(current_timestamp())`),
+			},
+			wantErr: true,
+		},
+		{
+			name: "notBool",
+			constraint: &cel2sql.CheckConstraint{
+				Name:     "title",
+				Table:    "wikipedia",
+				Variable: "page",
+				Rule:     compile(`page.title`),
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalidName",
+			constraint: &cel2sql.CheckConstraint{
+				Name:     "valid page",
+				Table:    "wikipedia",
+				Variable: "page",
+				Rule:     compile(`page.num_characters >= 0`),
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := cel2sql.CheckConstraintDDL(tt.constraint)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestCheckAssertion(t *testing.T) {
+	compile := newCheckConstraintEnv(t)
+
+	tests := []struct {
+		name       string
+		constraint *cel2sql.CheckConstraint
+		want       string
+		wantErr    bool
+	}{
+		{
+			name: "rule",
+			constraint: &cel2sql.CheckConstraint{
+				Name:     "valid_page",
+				Table:    "project.dataset.wikipedia",
+				Variable: "page",
+				Rule:     compile(`size(page.title) > 0 && page.num_characters >= 0`),
+			},
+			want: "ASSERT NOT EXISTS(SELECT * FROM `project`.`dataset`.`wikipedia` WHERE NOT (LENGTH(`title`) > 0 AND `num_characters` >= 0)) AS \"valid_page\"",
+		},
+		{
+			name: "sqltypes",
+			constraint: &cel2sql.CheckConstraint{
+				Name:     "timestamp",
+				Table:    "wikipedia",
+				Variable: "page",
+				Rule:     compile(`page.timestamp < int(current_timestamp())`),
+			},
+			want: "ASSERT NOT EXISTS(SELECT * FROM `wikipedia` WHERE NOT (`timestamp` < UNIX_SECONDS(CURRENT_TIMESTAMP()))) AS \"timestamp\"",
+		},
+		{
+			name: "otherVariable",
+			constraint: &cel2sql.CheckConstraint{
+				Name:     "language",
+				Table:    "wikipedia",
+				Variable: "page",
+				Rule:     compile(`page.language == language`),
+			},
+			wantErr: true,
+		},
+		{
+			name: "emptyTable",
+			constraint: &cel2sql.CheckConstraint{
+				Name:     "valid_page",
+				Variable: "page",
+				Rule:     compile(`page.num_characters >= 0`),
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := cel2sql.CheckAssertion(tt.constraint)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
@@ -0,0 +1,197 @@
+package composite
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/google/cel-go/common/types"
+	"github.com/google/cel-go/common/types/ref"
+	exprpb "google.golang.org/genproto/googleapis/api/expr/v1alpha1"
+)
+
+// TypeNamer is implemented by type providers which are able to enumerate the type names they
+// define. Such providers are checked for conflicts eagerly by NewTypeProvider.
+type TypeNamer interface {
+	TypeNames() []string
+}
+
+// Nullability is implemented by type providers which know whether the fields of their types may
+// be NULL, e.g. by the modes of BigQuery columns.
+type Nullability interface {
+	IsRequired(typeName string, fieldName string) bool
+}
+
+// Partitioner is implemented by type providers which know the partitioning of their tables.
+type Partitioner interface {
+	PartitionField(typeName string) (string, bool)
+	RequiresPartitionFilter(typeName string) bool
+}
+
+// PolicyTagger is implemented by type providers which restrict the access to the fields of their
+// types by policy tags.
+type PolicyTagger interface {
+	RestrictedPolicyTags(typeName string, fieldName string) []string
+}
+
+// ConflictError reports a type name which is defined by more than one provider.
+type ConflictError struct {
+	TypeName  string
+	Providers []int
+}
+
+func (e *ConflictError) Error() string {
+	indexes := make([]string, len(e.Providers))
+	for i, index := range e.Providers {
+		indexes[i] = fmt.Sprint(index)
+	}
+	return fmt.Sprintf("type \"%s\" is defined by multiple providers (%s)", e.TypeName, strings.Join(indexes, ", "))
+}
+
+type typeProvider struct {
+	providers []ref.TypeProvider
+}
+
+// NewTypeProvider returns a ref.TypeProvider which delegates to the given providers in order.
+//
+// Providers implementing TypeNamer are checked for conflicting type names up front, and a
+// *ConflictError is returned when two of them define the same type. The other providers cannot be
+// checked, e.g. protobuf registries all define the well-known types, so every lookup, including
+// types, identifiers and enum values, resolves to the first provider defining the name.
+func NewTypeProvider(providers ...ref.TypeProvider) (*typeProvider, error) {
+	owners := map[string][]int{}
+	var typeNames []string
+	for i, provider := range providers {
+		namer, ok := provider.(TypeNamer)
+		if !ok {
+			continue
+		}
+		for _, typeName := range namer.TypeNames() {
+			if _, found := owners[typeName]; !found {
+				typeNames = append(typeNames, typeName)
+			}
+			owners[typeName] = append(owners[typeName], i)
+		}
+	}
+	for _, typeName := range typeNames {
+		if len(owners[typeName]) > 1 {
+			return nil, &ConflictError{TypeName: typeName, Providers: owners[typeName]}
+		}
+	}
+	return &typeProvider{providers: providers}, nil
+}
+
+// findOwner returns the first provider defining the type name.
+func (p *typeProvider) findOwner(typeName string) (ref.TypeProvider, *exprpb.Type, bool) {
+	for _, provider := range p.providers {
+		if typ, found := provider.FindType(typeName); found {
+			return provider, typ, true
+		}
+	}
+	return nil, nil, false
+}
+
+func (p *typeProvider) EnumValue(enumName string) ref.Val {
+	for _, provider := range p.providers {
+		if val := provider.EnumValue(enumName); !types.IsError(val) {
+			return val
+		}
+	}
+	return types.NewErr("unknown enum name '%s'", enumName)
+}
+
+func (p *typeProvider) FindIdent(identName string) (ref.Val, bool) {
+	for _, provider := range p.providers {
+		if val, found := provider.FindIdent(identName); found {
+			return val, true
+		}
+	}
+	return nil, false
+}
+
+func (p *typeProvider) FindType(typeName string) (*exprpb.Type, bool) {
+	_, typ, found := p.findOwner(typeName)
+	return typ, found
+}
+
+func (p *typeProvider) FindFieldType(messageType string, fieldName string) (*ref.FieldType, bool) {
+	owner, _, found := p.findOwner(messageType)
+	if !found {
+		return nil, false
+	}
+	return owner.FindFieldType(messageType, fieldName)
+}
+
+func (p *typeProvider) NewValue(typeName string, fields map[string]ref.Val) ref.Val {
+	owner, _, found := p.findOwner(typeName)
+	if !found {
+		return types.NewErr("unknown type '%s'", typeName)
+	}
+	return owner.NewValue(typeName, fields)
+}
+
+// TypeNames returns the type names of the providers implementing TypeNamer.
+func (p *typeProvider) TypeNames() []string {
+	var typeNames []string
+	for _, provider := range p.providers {
+		if namer, ok := provider.(TypeNamer); ok {
+			typeNames = append(typeNames, namer.TypeNames()...)
+		}
+	}
+	return typeNames
+}
+
+// IsRequired reports whether the field is required by the provider defining the type, if it
+// implements Nullability.
+func (p *typeProvider) IsRequired(typeName string, fieldName string) bool {
+	owner, _, found := p.findOwner(typeName)
+	if !found {
+		return false
+	}
+	nullability, ok := owner.(Nullability)
+	return ok && nullability.IsRequired(typeName, fieldName)
+}
+
+// PartitionField returns the partitioning field of the provider defining the type, if it
+// implements Partitioner.
+func (p *typeProvider) PartitionField(typeName string) (string, bool) {
+	owner, _, found := p.findOwner(typeName)
+	if !found {
+		return "", false
+	}
+	partitioner, ok := owner.(Partitioner)
+	if !ok {
+		return "", false
+	}
+	return partitioner.PartitionField(typeName)
+}
+
+// RequiresPartitionFilter reports whether the provider defining the type, if it implements
+// Partitioner, requires a partition filter.
+func (p *typeProvider) RequiresPartitionFilter(typeName string) bool {
+	owner, _, found := p.findOwner(typeName)
+	if !found {
+		return false
+	}
+	partitioner, ok := owner.(Partitioner)
+	return ok && partitioner.RequiresPartitionFilter(typeName)
+}
+
+// RestrictedPolicyTags returns the restricted policy tags of the field by the provider defining
+// the type, if it implements PolicyTagger.
+func (p *typeProvider) RestrictedPolicyTags(typeName string, fieldName string) []string {
+	owner, _, found := p.findOwner(typeName)
+	if !found {
+		return nil
+	}
+	policyTagger, ok := owner.(PolicyTagger)
+	if !ok {
+		return nil
+	}
+	return policyTagger.RestrictedPolicyTags(typeName, fieldName)
+}
+
+var _ ref.TypeProvider = new(typeProvider)
+var _ TypeNamer = new(typeProvider)
+var _ Nullability = new(typeProvider)
+var _ Partitioner = new(typeProvider)
+var _ PolicyTagger = new(typeProvider)
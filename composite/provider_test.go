@@ -0,0 +1,170 @@
+package composite_test
+
+import (
+	"testing"
+
+	"cloud.google.com/go/bigquery"
+	"github.com/google/cel-go/cel"
+	"github.com/google/cel-go/checker/decls"
+	"github.com/google/cel-go/common/types"
+	"github.com/google/cel-go/common/types/ref"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	exprpb "google.golang.org/genproto/googleapis/api/expr/v1alpha1"
+
+	"github.com/cockscomb/cel2sql"
+	"github.com/cockscomb/cel2sql/bq"
+	"github.com/cockscomb/cel2sql/composite"
+	"github.com/cockscomb/cel2sql/sqltypes"
+	"github.com/cockscomb/cel2sql/test"
+)
+
+func newProtoRegistry(t *testing.T) ref.TypeRegistry {
+	registry, err := types.NewRegistry(&exprpb.Constant{})
+	require.NoError(t, err)
+	return registry
+}
+
+// opaqueProvider hides the TypeNamer implementation of the underlying provider.
+type opaqueProvider struct {
+	ref.TypeProvider
+}
+
+func TestNewTypeProvider(t *testing.T) {
+	trigrams := bq.NewTypeProvider(map[string]bigquery.Schema{
+		"trigrams": test.NewTrigramsTableMetadata().Schema,
+	})
+	wikipedia := bq.NewTypeProvider(map[string]bigquery.Schema{
+		"wikipedia": test.NewWikipediaTableMetadata().Schema,
+	})
+	alsoWikipedia := bq.NewTypeProvider(map[string]bigquery.Schema{
+		"wikipedia": test.NewWikipediaTableMetadata().Schema,
+	})
+
+	tests := []struct {
+		name      string
+		providers []ref.TypeProvider
+		wantErr   error
+	}{
+		{
+			name:      "distinct",
+			providers: []ref.TypeProvider{trigrams, wikipedia, newProtoRegistry(t)},
+			wantErr:   nil,
+		},
+		{
+			name:      "conflict",
+			providers: []ref.TypeProvider{trigrams, wikipedia, alsoWikipedia},
+			wantErr:   &composite.ConflictError{TypeName: "wikipedia", Providers: []int{1, 2}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := composite.NewTypeProvider(tt.providers...)
+			assert.Equal(t, tt.wantErr, err)
+		})
+	}
+}
+
+func Test_typeProvider_FindType(t *testing.T) {
+	typeProvider, err := composite.NewTypeProvider(
+		bq.NewTypeProvider(map[string]bigquery.Schema{
+			"trigrams":  test.NewTrigramsTableMetadata().Schema,
+			"wikipedia": test.NewWikipediaTableMetadata().Schema,
+		}),
+		newProtoRegistry(t),
+		opaqueProvider{bq.NewTypeProvider(map[string]bigquery.Schema{
+			"wikipedia": test.NewWikipediaTableMetadata().Schema,
+		})},
+	)
+	require.NoError(t, err)
+
+	type args struct {
+		typeName string
+	}
+	tests := []struct {
+		name      string
+		args      args
+		want      *exprpb.Type
+		wantFound bool
+	}{
+		{
+			name:      "trigrams.cell",
+			args:      args{typeName: "trigrams.cell"},
+			want:      decls.NewTypeType(decls.NewObjectType("trigrams.cell")),
+			wantFound: true,
+		},
+		{
+			name:      "google.protobuf.Timestamp",
+			args:      args{typeName: "google.protobuf.Timestamp"},
+			want:      decls.NewTypeType(decls.NewObjectType("google.protobuf.Timestamp")),
+			wantFound: true,
+		},
+		{
+			name:      "firstProvider",
+			args:      args{typeName: "wikipedia"},
+			want:      decls.NewTypeType(decls.NewObjectType("wikipedia")),
+			wantFound: true,
+		},
+		{
+			name:      "not_exists",
+			args:      args{typeName: "not_exists"},
+			want:      nil,
+			wantFound: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, gotFound := typeProvider.FindType(tt.args.typeName)
+			if assert.Equal(t, tt.wantFound, gotFound) {
+				assert.Equal(t, tt.want, got)
+			}
+		})
+	}
+}
+
+func Test_typeProvider_wellKnownTypes(t *testing.T) {
+	typeProvider, err := composite.NewTypeProvider(
+		bq.NewTypeProvider(map[string]bigquery.Schema{
+			"wikipedia": test.NewWikipediaTableMetadata().Schema,
+		}),
+		newProtoRegistry(t),
+		newProtoRegistry(t),
+	)
+	require.NoError(t, err)
+	env, err := cel.NewEnv(cel.CustomTypeProvider(typeProvider))
+	require.NoError(t, err)
+
+	// the registries both define the well-known types, which resolve to the first of them.
+	ast, issues := env.Compile(`google.protobuf.Int64Value{value: 1} == 1`)
+	require.Empty(t, issues)
+	program, err := env.Program(ast)
+	require.NoError(t, err)
+	got, _, err := program.Eval(map[string]interface{}{})
+	require.NoError(t, err)
+	assert.Equal(t, types.True, got)
+}
+
+func Test_typeProvider_Convert(t *testing.T) {
+	typeProvider, err := composite.NewTypeProvider(
+		bq.NewTypeProvider(map[string]bigquery.Schema{
+			"wikipedia": test.NewWikipediaTableMetadata().Schema,
+		}),
+		newProtoRegistry(t),
+	)
+	require.NoError(t, err)
+	env, err := cel.NewEnv(
+		cel.CustomTypeProvider(typeProvider),
+		sqltypes.SQLTypeDeclarations,
+		cel.Declarations(
+			decls.NewVar("page", decls.NewObjectType("wikipedia")),
+			decls.NewVar("request", decls.NewObjectType("google.api.expr.v1alpha1.Constant")),
+		),
+	)
+	require.NoError(t, err)
+
+	ast, issues := env.Compile(`page.title == request.string_value && page.id > request.int64_value`)
+	require.Empty(t, issues)
+	got, err := cel2sql.Convert(ast)
+	require.NoError(t, err)
+	assert.Equal(t, "`page`.`title` = `request`.`string_value` AND `page`.`id` > `request`.`int64_value`", got)
+}
@@ -0,0 +1,146 @@
+package cel2sql
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/google/cel-go/cel"
+	exprpb "google.golang.org/genproto/googleapis/api/expr/v1alpha1"
+)
+
+// Dialect is the SQL dialect rendered by the conversion.
+type Dialect int
+
+const (
+	// BigQuery is the standard SQL of BigQuery, which is the default.
+	BigQuery Dialect = iota
+	// PostgreSQL renders the operators and functions of CEL for PostgreSQL. The functions specific
+	// to BigQuery, e.g. those of sqltypes, comprehensions, and the timestamp accessors are not
+	// supported.
+	PostgreSQL
+)
+
+func (d Dialect) String() string {
+	switch d {
+	case BigQuery:
+		return "BigQuery"
+	case PostgreSQL:
+		return "PostgreSQL"
+	}
+	return fmt.Sprintf("Dialect(%d)", int(d))
+}
+
+// SQLDialect renders the SQL of the dialect.
+func SQLDialect(dialect Dialect) ConvertOption {
+	return func(con *converter) {
+		con.dialect = dialect
+	}
+}
+
+// SessionSettings renders the variables as the PostgreSQL settings of the session, e.g. `tenant`
+// as `current_setting('app.tenant')`, which is cast to the type of the variable.
+func SessionSettings(settings map[string]string) ConvertOption {
+	return func(con *converter) {
+		if con.sessionSettings == nil {
+			con.sessionSettings = map[string]string{}
+		}
+		for name, setting := range settings {
+			con.sessionSettings[name] = setting
+		}
+	}
+}
+
+// quoteIdentifier quotes the identifier, e.g. `name` in BigQuery and "name" in PostgreSQL. The
+// quotes in the name are escaped, as the names may be given by the callbacks of the options.
+func (con *converter) quoteIdentifier(name string) string {
+	if con.dialect == PostgreSQL {
+		return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+	}
+	return "`" + bigQueryIdentifierEscaper.Replace(name) + "`"
+}
+
+// bigQueryIdentifierEscaper escapes the backslashes and the backticks of a quoted identifier of
+// BigQuery.
+var bigQueryIdentifierEscaper = strings.NewReplacer(`\`, `\\`, "`", "\\`")
+
+// quoteQualifiedName quotes each part of a dot separated name in the dialect.
+func (con *converter) quoteQualifiedName(name string) string {
+	parts := strings.Split(name, ".")
+	for i, part := range parts {
+		parts[i] = con.quoteIdentifier(part)
+	}
+	return strings.Join(parts, ".")
+}
+
+// parameter returns the query parameter of the name, e.g. @name in BigQuery and $1 in PostgreSQL,
+// which is numbered by the position of the name among the sorted names of the parameters.
+func (con *converter) parameter(name string) string {
+	if con.dialect != PostgreSQL {
+		return "@" + name
+	}
+	position := 1
+	for other := range con.parameters {
+		if other < name {
+			position++
+		}
+	}
+	return "$" + strconv.Itoa(position)
+}
+
+// quotePostgresString returns the string literal of PostgreSQL.
+func quotePostgresString(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
+
+func quotePostgresBytes(b []byte) string {
+	return `'\x` + hex.EncodeToString(b) + `'::BYTEA`
+}
+
+// postgresType returns the PostgreSQL type of the CEL type.
+func postgresType(typ *exprpb.Type) (string, bool) {
+	switch typ.GetPrimitive() {
+	case exprpb.Type_BOOL:
+		return "BOOLEAN", true
+	case exprpb.Type_BYTES:
+		return "BYTEA", true
+	case exprpb.Type_DOUBLE:
+		return "DOUBLE PRECISION", true
+	case exprpb.Type_INT64, exprpb.Type_UINT64:
+		return "BIGINT", true
+	case exprpb.Type_STRING:
+		return "TEXT", true
+	}
+	switch typ.GetWellKnown() {
+	case exprpb.Type_TIMESTAMP:
+		return "TIMESTAMPTZ", true
+	case exprpb.Type_DURATION:
+		return "INTERVAL", true
+	}
+	return "", false
+}
+
+// writeSessionSetting writes the setting of the session cast to the type.
+func (con *converter) writeSessionSetting(setting string, typ *exprpb.Type) error {
+	value := "current_setting(" + quotePostgresString(setting) + ")"
+	if typ.GetPrimitive() == exprpb.Type_STRING {
+		con.str.WriteString(value)
+		return nil
+	}
+	castType, found := postgresType(typ)
+	if !found {
+		return &UnsupportedTypeError{Type: cel.FormatType(typ)}
+	}
+	con.str.WriteString("CAST(")
+	con.str.WriteString(value)
+	con.str.WriteString(" AS ")
+	con.str.WriteString(castType)
+	con.str.WriteString(")")
+	return nil
+}
+
+// unsupportedFunctionError reports the function which the dialect does not support.
+func (con *converter) unsupportedFunctionError(function string) error {
+	return &UnsupportedFunctionError{Function: function, Dialect: con.dialect}
+}
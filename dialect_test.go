@@ -0,0 +1,191 @@
+package cel2sql_test
+
+import (
+	"testing"
+
+	"cloud.google.com/go/bigquery"
+	"github.com/google/cel-go/cel"
+	"github.com/google/cel-go/checker/decls"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/cockscomb/cel2sql"
+	"github.com/cockscomb/cel2sql/bq"
+	"github.com/cockscomb/cel2sql/sqltypes"
+	"github.com/cockscomb/cel2sql/test"
+)
+
+func TestConvert_postgreSQL(t *testing.T) {
+	env, err := cel.NewEnv(
+		cel.CustomTypeProvider(bq.NewTypeProvider(map[string]bigquery.Schema{
+			"wikipedia": test.NewWikipediaTableMetadata().Schema,
+		})),
+		sqltypes.SQLTypeDeclarations,
+		cel.Declarations(
+			decls.NewVar("name", decls.String),
+			decls.NewVar("age", decls.Int),
+			decls.NewVar("data", decls.Bytes),
+			decls.NewVar("string_list", decls.NewListType(decls.String)),
+			decls.NewVar("string_int_map", decls.NewMapType(decls.String, decls.Int)),
+			decls.NewVar("created_at", decls.Timestamp),
+			decls.NewVar("birthday", sqltypes.Date),
+			decls.NewVar("tenant", decls.String),
+			decls.NewVar("level", decls.Int),
+			decls.NewVar("page", decls.NewObjectType("wikipedia")),
+		),
+	)
+	require.NoError(t, err)
+	settings := cel2sql.SessionSettings(map[string]string{"tenant": "app.tenant", "level": "app.level"})
+
+	tests := []struct {
+		name    string
+		source  string
+		want    string
+		wantErr bool
+	}{
+		{
+			name:   "identifier",
+			source: `page.title == "It's"`,
+			want:   `"page"."title" = 'It''s'`,
+		},
+		{
+			name:   "bytes",
+			source: `data == b"abc"`,
+			want:   `"data" = '\x616263'::BYTEA`,
+		},
+		{
+			name:   "inList",
+			source: `name in ["a", "b"]`,
+			want:   `"name" = ANY(ARRAY['a', 'b'])`,
+		},
+		{
+			name:   "inVariable",
+			source: `name in string_list`,
+			want:   `"name" = ANY("string_list")`,
+		},
+		{
+			name:   "listIndex",
+			source: `string_list[age - 1] == "a"`,
+			want:   `"string_list"["age" - 1 + 1] = 'a'`,
+		},
+		{
+			name:   "listLiteralIndex",
+			source: `["a", name][age] == "a"`,
+			want:   `(ARRAY['a', "name"])["age" + 1] = 'a'`,
+		},
+		{
+			name:   "mapIndex",
+			source: `string_int_map.one == 1`,
+			want:   `"string_int_map"."one" = 1`,
+		},
+		{
+			name:   "conditional",
+			source: `age > 20 ? "adult" : "child"`,
+			want:   `CASE WHEN "age" > 20 THEN 'adult' ELSE 'child' END`,
+		},
+		{
+			name:   "contains",
+			source: `name.contains("a")`,
+			want:   `STRPOS("name", 'a') != 0`,
+		},
+		{
+			name:   "startsWith",
+			source: `name.startsWith("a")`,
+			want:   `STARTS_WITH("name", 'a')`,
+		},
+		{
+			name:   "endsWith",
+			source: `name.endsWith("z")`,
+			want:   `RIGHT("name", LENGTH('z')) = 'z'`,
+		},
+		{
+			name:   "matches",
+			source: `name.matches("^a+$")`,
+			want:   `"name" ~ '^a+$'`,
+		},
+		{
+			name:   "size",
+			source: `size(name) > 0 && size(string_list) < 3`,
+			want:   `LENGTH("name") > 0 AND CARDINALITY("string_list") < 3`,
+		},
+		{
+			name:   "modulo",
+			source: `age % 2 == 0`,
+			want:   `MOD("age", 2) = 0`,
+		},
+		{
+			name:   "timestampArithmetic",
+			source: `created_at + duration("24h") > timestamp("2021-01-01T00:00:00Z")`,
+			want:   `"created_at" + INTERVAL '24 HOUR' > CAST('2021-01-01T00:00:00Z' AS TIMESTAMPTZ)`,
+		},
+		{
+			name:   "casting",
+			source: `int(created_at) > 0 && string(age) == "1" && double(age) > 0.5`,
+			want:   `CAST(EXTRACT(EPOCH FROM "created_at") AS BIGINT) > 0 AND CAST("age" AS TEXT) = '1' AND CAST("age" AS DOUBLE PRECISION) > 0.5`,
+		},
+		{
+			name:   "timestampFromInt",
+			source: `timestamp(age) < created_at`,
+			want:   `TO_TIMESTAMP("age") < "created_at"`,
+		},
+		{
+			name:   "sessionSettings",
+			source: `page.language == tenant && page.wp_namespace <= level`,
+			want:   `"page"."language" = current_setting('app.tenant') AND "page"."wp_namespace" <= CAST(current_setting('app.level') AS BIGINT)`,
+		},
+		{
+			name:    "timestampGetter",
+			source:  `created_at.getFullYear() == 2021`,
+			wantErr: true,
+		},
+		{
+			name:    "sqltypes",
+			source:  `birthday > current_date()`,
+			wantErr: true,
+		},
+		{
+			name:    "comprehension",
+			source:  `string_list.exists(s, s == "a")`,
+			wantErr: true,
+		},
+		{
+			name:    "mapLiteral",
+			source:  `{"a": 1}.a == 1`,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ast, issues := env.Compile(tt.source)
+			require.Empty(t, issues)
+
+			got, err := cel2sql.Convert(ast, cel2sql.SQLDialect(cel2sql.PostgreSQL), settings)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestQueryParameters_postgreSQL(t *testing.T) {
+	env, err := cel.NewEnv(
+		cel.Declarations(
+			decls.NewVar("name", decls.String),
+			decls.NewVar("cursor_0", decls.String),
+			decls.NewVar("cursor_1", decls.Int),
+		),
+	)
+	require.NoError(t, err)
+	parameters := map[string]interface{}{"cursor_1": int64(42), "cursor_0": "a"}
+
+	ast, issues := env.Compile(`name > cursor_0 || name == cursor_0 && size(name) > cursor_1`)
+	require.Empty(t, issues)
+
+	got, err := cel2sql.Convert(ast, cel2sql.SQLDialect(cel2sql.PostgreSQL), cel2sql.QueryParameters(parameters))
+	require.NoError(t, err)
+	assert.Equal(t, `"name" > $1 OR "name" = $1 AND LENGTH("name") > $2`, got)
+	assert.Equal(t, []interface{}{"a", int64(42)}, cel2sql.PositionalParameters(parameters))
+}
@@ -0,0 +1,140 @@
+package cel2sql
+
+import (
+	"fmt"
+
+	"cloud.google.com/go/bigquery"
+	"github.com/google/cel-go/cel"
+	"github.com/google/cel-go/checker/decls"
+	"github.com/google/cel-go/common/types/ref"
+	exprpb "google.golang.org/genproto/googleapis/api/expr/v1alpha1"
+
+	"github.com/cockscomb/cel2sql/bq"
+	"github.com/cockscomb/cel2sql/composite"
+	"github.com/cockscomb/cel2sql/sqltypes"
+)
+
+// EnvOption configures the environment built by NewEnv.
+type EnvOption func(*envConfig)
+
+type tableVariable struct {
+	name  string
+	table string
+}
+
+type envConfig struct {
+	tableVariables []tableVariable
+	rowTable       string
+	declarations   []*exprpb.Decl
+	typeProviders  []ref.TypeProvider
+	providerOpts   []bq.Option
+}
+
+// TableVariable declares a variable of the table's row type, e.g. `trigram` for `trigrams`.
+func TableVariable(name string, table string) EnvOption {
+	return func(c *envConfig) {
+		c.tableVariables = append(c.tableVariables, tableVariable{name: name, table: table})
+	}
+}
+
+// RowTable declares each column of the table as a top-level identifier, so that expressions
+// can refer to `status` instead of `user.status`.
+func RowTable(table string) EnvOption {
+	return func(c *envConfig) {
+		c.rowTable = table
+	}
+}
+
+// Declarations adds extra declarations to the environment.
+func Declarations(declarations ...*exprpb.Decl) EnvOption {
+	return func(c *envConfig) {
+		c.declarations = append(c.declarations, declarations...)
+	}
+}
+
+// TypeProviders chains extra type providers after the BigQuery type provider.
+func TypeProviders(providers ...ref.TypeProvider) EnvOption {
+	return func(c *envConfig) {
+		c.typeProviders = append(c.typeProviders, providers...)
+	}
+}
+
+// ProviderOptions configures the BigQuery type provider, e.g. with bq.ColumnNames.
+func ProviderOptions(opts ...bq.Option) EnvOption {
+	return func(c *envConfig) {
+		c.providerOpts = append(c.providerOpts, opts...)
+	}
+}
+
+// NewEnv returns a CEL environment whose types are provided by the BigQuery schemas and which
+// includes the SQL type declarations.
+func NewEnv(schemas map[string]bigquery.Schema, opts ...EnvOption) (*cel.Env, error) {
+	config := newEnvConfig(opts)
+	return newEnv(schemas, bq.NewTypeProvider(schemas, config.providerOpts...), config)
+}
+
+// NewTableEnv is like NewEnv, but the types are provided by the metadata of the tables, which
+// includes their partitioning.
+func NewTableEnv(tables map[string]*bigquery.TableMetadata, opts ...EnvOption) (*cel.Env, error) {
+	config := newEnvConfig(opts)
+	schemas := make(map[string]bigquery.Schema, len(tables))
+	for name, table := range tables {
+		schemas[name] = table.Schema
+	}
+	return newEnv(schemas, bq.NewTableTypeProvider(tables, config.providerOpts...), config)
+}
+
+func newEnvConfig(opts []EnvOption) *envConfig {
+	config := &envConfig{}
+	for _, opt := range opts {
+		opt(config)
+	}
+	return config
+}
+
+// fieldNamer is the BigQuery type provider, which enumerates the fields of the tables.
+type fieldNamer interface {
+	ref.TypeProvider
+	FieldNames(typeName string) ([]string, bool)
+}
+
+func newEnv(schemas map[string]bigquery.Schema, bqProvider fieldNamer, config *envConfig) (*cel.Env, error) {
+	var typeProvider ref.TypeProvider = bqProvider
+	if len(config.typeProviders) > 0 {
+		providers := append([]ref.TypeProvider{bqProvider}, config.typeProviders...)
+		p, err := composite.NewTypeProvider(providers...)
+		if err != nil {
+			return nil, err
+		}
+		typeProvider = p
+	}
+
+	var declarations []*exprpb.Decl
+	for _, v := range config.tableVariables {
+		if _, found := schemas[v.table]; !found {
+			return nil, fmt.Errorf("unknown table \"%s\" for variable \"%s\"", v.table, v.name)
+		}
+		declarations = append(declarations, decls.NewVar(v.name, decls.NewObjectType(v.table)))
+	}
+	if config.rowTable != "" {
+		fieldNames, found := bqProvider.FieldNames(config.rowTable)
+		if !found {
+			return nil, fmt.Errorf("unknown table \"%s\"", config.rowTable)
+		}
+		for _, fieldName := range fieldNames {
+			fieldType, found := bqProvider.FindFieldType(config.rowTable, fieldName)
+			// columns of types unknown to CEL cannot be referenced.
+			if !found || fieldType.Type == nil {
+				continue
+			}
+			declarations = append(declarations, decls.NewVar(fieldName, fieldType.Type))
+		}
+	}
+	declarations = append(declarations, config.declarations...)
+
+	return cel.NewEnv(
+		cel.CustomTypeProvider(typeProvider),
+		sqltypes.SQLTypeDeclarations,
+		cel.Declarations(declarations...),
+	)
+}
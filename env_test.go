@@ -0,0 +1,127 @@
+package cel2sql_test
+
+import (
+	"testing"
+
+	"cloud.google.com/go/bigquery"
+	"github.com/google/cel-go/checker/decls"
+	"github.com/google/cel-go/common/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	exprpb "google.golang.org/genproto/googleapis/api/expr/v1alpha1"
+
+	"github.com/cockscomb/cel2sql"
+	"github.com/cockscomb/cel2sql/sqltypes"
+	"github.com/cockscomb/cel2sql/test"
+)
+
+func TestNewEnv(t *testing.T) {
+	schemas := map[string]bigquery.Schema{
+		"trigrams":  test.NewTrigramsTableMetadata().Schema,
+		"wikipedia": test.NewWikipediaTableMetadata().Schema,
+	}
+	registry, err := types.NewRegistry(&exprpb.Constant{})
+	require.NoError(t, err)
+
+	type args struct {
+		opts   []cel2sql.EnvOption
+		source string
+	}
+	tests := []struct {
+		name       string
+		args       args
+		want       string
+		wantEnvErr bool
+	}{
+		{
+			name: "tableVariable",
+			args: args{
+				opts: []cel2sql.EnvOption{
+					cel2sql.TableVariable("trigram", "trigrams"),
+					cel2sql.TableVariable("page", "wikipedia"),
+				},
+				source: `trigram.ngram == page.title`,
+			},
+			want: "`trigram`.`ngram` = `page`.`title`",
+		},
+		{
+			name: "rowTable",
+			args: args{
+				opts: []cel2sql.EnvOption{
+					cel2sql.RowTable("wikipedia"),
+				},
+				source: `title.startsWith("a") && num_characters > 100`,
+			},
+			want: "STARTS_WITH(`title`, \"a\") AND `num_characters` > 100",
+		},
+		{
+			name: "declarations",
+			args: args{
+				opts: []cel2sql.EnvOption{
+					cel2sql.RowTable("wikipedia"),
+					cel2sql.Declarations(decls.NewVar("min_length", decls.Int)),
+				},
+				source: `num_characters >= min_length`,
+			},
+			want: "`num_characters` >= `min_length`",
+		},
+		{
+			name: "sqlTypes",
+			args: args{
+				opts: []cel2sql.EnvOption{
+					cel2sql.Declarations(decls.NewVar("birthday", sqltypes.Date)),
+				},
+				source: `birthday > current_date() - interval(1, YEAR)`,
+			},
+			want: "`birthday` > DATE_SUB(CURRENT_DATE(), INTERVAL 1 YEAR)",
+		},
+		{
+			name: "typeProviders",
+			args: args{
+				opts: []cel2sql.EnvOption{
+					cel2sql.TableVariable("page", "wikipedia"),
+					cel2sql.TypeProviders(registry),
+					cel2sql.Declarations(decls.NewVar("request", decls.NewObjectType("google.api.expr.v1alpha1.Constant"))),
+				},
+				source: `page.title == request.string_value`,
+			},
+			want: "`page`.`title` = `request`.`string_value`",
+		},
+		{
+			name: "unknownTableVariable",
+			args: args{
+				opts: []cel2sql.EnvOption{
+					cel2sql.TableVariable("user", "users"),
+				},
+			},
+			wantEnvErr: true,
+		},
+		{
+			name: "unknownRowTable",
+			args: args{
+				opts: []cel2sql.EnvOption{
+					cel2sql.RowTable("users"),
+				},
+			},
+			wantEnvErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env, err := cel2sql.NewEnv(schemas, tt.args.opts...)
+			if tt.wantEnvErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+
+			ast, issues := env.Compile(tt.args.source)
+			require.Empty(t, issues)
+
+			got, err := cel2sql.Convert(ast)
+			if assert.NoError(t, err) {
+				assert.Equal(t, tt.want, got)
+			}
+		})
+	}
+}
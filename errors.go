@@ -0,0 +1,115 @@
+package cel2sql
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/google/cel-go/common"
+	exprpb "google.golang.org/genproto/googleapis/api/expr/v1alpha1"
+)
+
+// Location locates the offending sub-expression of a conversion error in the source of the
+// expression, e.g. to highlight it in the filter of a user.
+type Location struct {
+	// ExprID is the ID of the sub-expression, which is zero if unknown.
+	ExprID int64
+	// Line and Column are the one-based position of the sub-expression, which are zero if the
+	// source info of the checked expression does not have it, e.g. for ASTs built by Optimize.
+	Line   int
+	Column int
+}
+
+func (l *Location) location() *Location {
+	return l
+}
+
+// prefix returns the position as the prefix of the error messages, e.g. `1:5: `.
+func (l *Location) prefix() string {
+	if l.Line == 0 {
+		return ""
+	}
+	return fmt.Sprintf("%d:%d: ", l.Line, l.Column)
+}
+
+// locatedError is an error which reports the location of the offending sub-expression.
+type locatedError interface {
+	error
+	location() *Location
+}
+
+// UnsupportedExprError reports an expression which cannot be converted, e.g. a comprehension.
+type UnsupportedExprError struct {
+	Location
+	Reason string
+}
+
+func (e *UnsupportedExprError) Error() string {
+	return fmt.Sprintf("%sunsupported expression: %s", e.prefix(), e.Reason)
+}
+
+// UnsupportedFunctionError reports a function or an operator which cannot be converted in the
+// dialect.
+type UnsupportedFunctionError struct {
+	Location
+	Function string
+	Dialect  Dialect
+}
+
+func (e *UnsupportedFunctionError) Error() string {
+	return fmt.Sprintf("%sfunction \"%s\" is not supported in %s", e.prefix(), e.Function, e.Dialect)
+}
+
+// UnsupportedTypeError reports an operand whose type cannot be converted.
+type UnsupportedTypeError struct {
+	Location
+	Type string
+}
+
+func (e *UnsupportedTypeError) Error() string {
+	return fmt.Sprintf("%sunsupported type: %s", e.prefix(), e.Type)
+}
+
+// InvalidFieldNameError reports a field name which is not a valid column name, e.g. a key of a
+// map literal.
+type InvalidFieldNameError struct {
+	Location
+	Name string
+}
+
+func (e *InvalidFieldNameError) Error() string {
+	return fmt.Sprintf("%sinvalid field name \"%s\"", e.prefix(), e.Name)
+}
+
+// locateExpr sets the ID of the expression to the error without the ID.
+func locateExpr(err error, expr *exprpb.Expr) {
+	var located locatedError
+	if errors.As(err, &located) && located.location().ExprID == 0 {
+		located.location().ExprID = expr.GetId()
+	}
+}
+
+// locate sets the position of the sub-expression of the error from the source info.
+func (con *converter) locate(err error) {
+	var located locatedError
+	if !errors.As(err, &located) {
+		return
+	}
+	location := located.location()
+	offset, found := con.sourceInfo.GetPositions()[location.ExprID]
+	if location.ExprID == 0 || !found || location.Line != 0 {
+		return
+	}
+	if l, found := common.NewInfoSource(con.sourceInfo).OffsetLocation(offset); found {
+		location.Line = l.Line()
+		location.Column = l.Column() + 1
+	}
+}
+
+// isUnsupportedError reports whether the error is caused by an expression, a function or a type
+// which cannot be converted, rather than by a restriction of the options.
+func isUnsupportedError(err error) bool {
+	var exprErr *UnsupportedExprError
+	var functionErr *UnsupportedFunctionError
+	var typeErr *UnsupportedTypeError
+	return errors.As(err, &exprErr) || errors.As(err, &functionErr) || errors.As(err, &typeErr)
+}
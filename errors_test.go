@@ -0,0 +1,144 @@
+package cel2sql_test
+
+import (
+	"errors"
+	"testing"
+
+	"cloud.google.com/go/bigquery"
+	"github.com/google/cel-go/cel"
+	"github.com/google/cel-go/checker/decls"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	exprpb "google.golang.org/genproto/googleapis/api/expr/v1alpha1"
+
+	"github.com/cockscomb/cel2sql"
+	"github.com/cockscomb/cel2sql/bq"
+	"github.com/cockscomb/cel2sql/sqltypes"
+	"github.com/cockscomb/cel2sql/test"
+)
+
+func TestConvert_errors(t *testing.T) {
+	env, err := cel.NewEnv(
+		cel.CustomTypeProvider(bq.NewTypeProvider(map[string]bigquery.Schema{
+			"wikipedia": test.NewWikipediaTableMetadata().Schema,
+		})),
+		sqltypes.SQLTypeDeclarations,
+		cel.Declarations(
+			decls.NewVar("name", decls.String),
+			decls.NewVar("string_list", decls.NewListType(decls.String)),
+			decls.NewVar("created_at", decls.Timestamp),
+			decls.NewVar("page", decls.NewObjectType("wikipedia")),
+		),
+	)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name       string
+		source     string
+		opts       []cel2sql.ConvertOption
+		wantErr    interface{}
+		wantLine   int
+		wantColumn int
+		wantMsg    string
+	}{
+		{
+			name:       "unsupportedExpr",
+			source:     `name == "a" ||` + "\n" + `  string_list.exists(s, s == "b")`,
+			wantErr:    &cel2sql.UnsupportedExprError{},
+			wantLine:   2,
+			wantColumn: 21,
+			wantMsg:    "2:21: unsupported expression: comprehension",
+		},
+		{
+			name:       "unsupportedFunction",
+			source:     `page.id > 0 && created_at.getFullYear() == 2021`,
+			opts:       []cel2sql.ConvertOption{cel2sql.SQLDialect(cel2sql.PostgreSQL)},
+			wantErr:    &cel2sql.UnsupportedFunctionError{},
+			wantLine:   1,
+			wantColumn: 38,
+			wantMsg:    "1:38: function \"getFullYear\" is not supported in PostgreSQL",
+		},
+		{
+			name:       "unsupportedType",
+			source:     `size({"a": 1}) > 0`,
+			wantErr:    &cel2sql.UnsupportedTypeError{},
+			wantLine:   1,
+			wantColumn: 6,
+			wantMsg:    "1:6: unsupported type: map(string, int)",
+		},
+		{
+			name:       "invalidFieldName",
+			source:     `{"a b": 1}["a b"] == 1`,
+			wantErr:    &cel2sql.InvalidFieldNameError{},
+			wantLine:   1,
+			wantColumn: 2,
+			wantMsg:    "1:2: invalid field name \"a b\"",
+		},
+		{
+			name:       "invalidDuration",
+			source:     `created_at + duration("1x") > created_at`,
+			wantErr:    &cel2sql.UnsupportedExprError{},
+			wantLine:   1,
+			wantColumn: 23,
+		},
+		{
+			name:       "limit",
+			source:     `name.matches("a") || name.matches("b")`,
+			opts:       []cel2sql.ConvertOption{cel2sql.MaxRegexes(1)},
+			wantErr:    &cel2sql.LimitError{},
+			wantLine:   1,
+			wantColumn: 34,
+			wantMsg:    "1:34: limit of regular expressions exceeded: 1",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ast, issues := env.Compile(tt.source)
+			require.Empty(t, issues)
+
+			_, err := cel2sql.Convert(ast, tt.opts...)
+			require.Error(t, err)
+			var location *cel2sql.Location
+			switch want := tt.wantErr.(type) {
+			case *cel2sql.UnsupportedExprError:
+				require.True(t, errors.As(err, &want), "%v", err)
+				location = &want.Location
+			case *cel2sql.UnsupportedFunctionError:
+				require.True(t, errors.As(err, &want), "%v", err)
+				location = &want.Location
+			case *cel2sql.UnsupportedTypeError:
+				require.True(t, errors.As(err, &want), "%v", err)
+				location = &want.Location
+			case *cel2sql.InvalidFieldNameError:
+				require.True(t, errors.As(err, &want), "%v", err)
+				location = &want.Location
+			case *cel2sql.LimitError:
+				require.True(t, errors.As(err, &want), "%v", err)
+				location = &want.Location
+			}
+			assert.NotZero(t, location.ExprID)
+			assert.Equal(t, tt.wantLine, location.Line)
+			assert.Equal(t, tt.wantColumn, location.Column)
+			if tt.wantMsg != "" {
+				assert.EqualError(t, err, tt.wantMsg)
+			}
+		})
+	}
+}
+
+func TestConvert_errorsWithoutSourceInfo(t *testing.T) {
+	env, err := cel.NewEnv(cel.Declarations(decls.NewVar("string_list", decls.NewListType(decls.String))))
+	require.NoError(t, err)
+	ast, issues := env.Compile(`string_list.exists(s, s == "a")`)
+	require.Empty(t, issues)
+	checkedExpr, err := cel.AstToCheckedExpr(ast)
+	require.NoError(t, err)
+	checkedExpr.SourceInfo = &exprpb.SourceInfo{}
+
+	_, err = cel2sql.Convert(cel.CheckedExprToAst(checkedExpr))
+	var unsupportedErr *cel2sql.UnsupportedExprError
+	require.True(t, errors.As(err, &unsupportedErr), "%v", err)
+	assert.NotZero(t, unsupportedErr.ExprID)
+	assert.Zero(t, unsupportedErr.Line)
+	assert.EqualError(t, err, "unsupported expression: comprehension")
+}
@@ -0,0 +1,171 @@
+package cel2sql
+
+import (
+	"fmt"
+
+	"github.com/google/cel-go/common/operators"
+	"github.com/google/cel-go/parser"
+	exprpb "google.golang.org/genproto/googleapis/api/expr/v1alpha1"
+)
+
+// OperatorNotAllowedError is returned when an operator or a function is applied to a field which
+// does not allow it.
+type OperatorNotAllowedError struct {
+	// Location is the location of the offending sub-expression.
+	Location
+	// Field is the field path, e.g. `page.title`.
+	Field string
+	// Operator is the operator or the function, e.g. `==` or `matches`.
+	Operator string
+	// Expr is the source of the offending sub-expression, which is empty if it cannot be
+	// unparsed.
+	Expr string
+}
+
+func (e *OperatorNotAllowedError) Error() string {
+	if e.Expr == "" {
+		return fmt.Sprintf("%s%s is not allowed on field \"%s\"", e.prefix(), e.Operator, e.Field)
+	}
+	return fmt.Sprintf("%s%s is not allowed on field \"%s\": %s", e.prefix(), e.Operator, e.Field, e.Expr)
+}
+
+// hasOperator and comprehensionOperator are the names of has() and the comprehensions in the
+// allowed operators.
+const (
+	hasOperator           = "has"
+	comprehensionOperator = "comprehension"
+)
+
+// structuralOperators are always allowed, as they do not inspect the values of the fields.
+var structuralOperators = map[string]bool{
+	operators.LogicalAnd:  true,
+	operators.LogicalOr:   true,
+	operators.LogicalNot:  true,
+	operators.Conditional: true,
+}
+
+// AllowedOperators restricts the operators and the functions applied to the field paths, e.g.
+// `page.title` to `==` and `startsWith`, or `title` for the columns of RowColumns. The operators
+// are written as in CEL, e.g. `==`, `<`, `in`, `matches` and `size`, and `has` allows has(). A
+// listed field is rejected with *OperatorNotAllowedError as the operand of other operators or
+// functions, and as the range of comprehensions unless `comprehension` is allowed. A field passed
+// through a conditional, a list or map literal, or an index is restricted as the field itself. The
+// logical operators and the conditional operator are always allowed, and the fields not listed are
+// not restricted. The predicates of RowPolicy are not restricted either.
+func AllowedOperators(fields map[string][]string) ConvertOption {
+	return func(con *converter) {
+		if con.allowedOperators == nil {
+			con.allowedOperators = map[string]map[string]bool{}
+		}
+		for field, ops := range fields {
+			allowed := map[string]bool{}
+			for _, op := range ops {
+				allowed[op] = true
+			}
+			con.allowedOperators[field] = allowed
+		}
+	}
+}
+
+// checkAllowedOperators returns *OperatorNotAllowedError if an operator not allowed is applied to
+// a field in the expression.
+func (con *converter) checkAllowedOperators(expr *exprpb.Expr) error {
+	if con.allowedOperators == nil {
+		return nil
+	}
+	var err error
+	check := func(e *exprpb.Expr, field string, operator string) {
+		allowed, found := con.allowedOperators[field]
+		if err != nil || !found || allowed[operator] {
+			return
+		}
+		source, unparseErr := parser.Unparse(e, con.sourceInfo)
+		if unparseErr != nil {
+			source = ""
+		}
+		err = &OperatorNotAllowedError{Location: Location{ExprID: e.GetId()}, Field: field, Operator: operator, Expr: source}
+	}
+	walkExpr(expr, func(e *exprpb.Expr) {
+		if err != nil {
+			return
+		}
+		switch e.ExprKind.(type) {
+		case *exprpb.Expr_CallExpr:
+			c := e.GetCallExpr()
+			if structuralOperators[c.GetFunction()] {
+				return
+			}
+			operator := c.GetFunction()
+			if op, found := operators.FindReverse(operator); found {
+				operator = op
+			}
+			operands := c.GetArgs()
+			if c.GetTarget() != nil {
+				operands = append([]*exprpb.Expr{c.GetTarget()}, operands...)
+			}
+			for _, operand := range operands {
+				for _, field := range operandFields(operand) {
+					check(e, field, operator)
+				}
+			}
+		case *exprpb.Expr_SelectExpr:
+			sel := e.GetSelectExpr()
+			if !sel.GetTestOnly() {
+				return
+			}
+			for _, operand := range operandFields(sel.GetOperand()) {
+				check(e, operand+"."+sel.GetField(), hasOperator)
+			}
+		case *exprpb.Expr_ComprehensionExpr:
+			for _, field := range operandFields(e.GetComprehensionExpr().GetIterRange()) {
+				check(e, field, comprehensionOperator)
+			}
+		}
+	})
+	return err
+}
+
+// operandFields returns the dot separated paths of the fields selected from variables whose values
+// the expression may evaluate to, e.g. `page.title` for `page.title`, for both branches of a
+// conditional, for the elements of a list or map literal, and for an element indexed from them, so
+// that a restricted field cannot be passed through them to an operator.
+func operandFields(expr *exprpb.Expr) []string {
+	switch expr.ExprKind.(type) {
+	case *exprpb.Expr_IdentExpr:
+		return []string{expr.GetIdentExpr().GetName()}
+	case *exprpb.Expr_SelectExpr:
+		sel := expr.GetSelectExpr()
+		if sel.GetTestOnly() {
+			return nil
+		}
+		var fields []string
+		for _, operand := range operandFields(sel.GetOperand()) {
+			fields = append(fields, operand+"."+sel.GetField())
+		}
+		return fields
+	case *exprpb.Expr_ListExpr:
+		var fields []string
+		for _, element := range expr.GetListExpr().GetElements() {
+			fields = append(fields, operandFields(element)...)
+		}
+		return fields
+	case *exprpb.Expr_StructExpr:
+		if expr.GetStructExpr().GetMessageName() != "" {
+			return nil
+		}
+		var fields []string
+		for _, entry := range expr.GetStructExpr().GetEntries() {
+			fields = append(fields, operandFields(entry.GetValue())...)
+		}
+		return fields
+	case *exprpb.Expr_CallExpr:
+		c := expr.GetCallExpr()
+		switch c.GetFunction() {
+		case operators.Conditional:
+			return append(operandFields(c.GetArgs()[1]), operandFields(c.GetArgs()[2])...)
+		case operators.Index:
+			return operandFields(c.GetArgs()[0])
+		}
+	}
+	return nil
+}
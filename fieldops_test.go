@@ -0,0 +1,175 @@
+package cel2sql_test
+
+import (
+	"errors"
+	"testing"
+
+	"cloud.google.com/go/bigquery"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/cockscomb/cel2sql"
+	"github.com/cockscomb/cel2sql/test"
+)
+
+func TestAllowedOperators(t *testing.T) {
+	env, err := cel2sql.NewEnv(
+		map[string]bigquery.Schema{
+			"wikipedia": test.NewWikipediaTableMetadata().Schema,
+			"trigrams":  test.NewTrigramsTableMetadata().Schema,
+		},
+		cel2sql.TableVariable("page", "wikipedia"),
+		cel2sql.TableVariable("trigram", "trigrams"),
+	)
+	require.NoError(t, err)
+	allowed := cel2sql.AllowedOperators(map[string][]string{
+		"page.title":    {"==", "startsWith", "matches"},
+		"page.language": {"==", "in"},
+		"page.id":       {"==", "<", ">"},
+		"page.comment":  {"has"},
+		"trigram.cell":  {"=="},
+	})
+
+	tests := []struct {
+		name         string
+		source       string
+		want         string
+		wantOperator string
+		wantField    string
+		wantExpr     string
+	}{
+		{
+			name:   "allowed",
+			source: `page.title.startsWith("A") && page.language in ["en", "ja"] && page.id > 10`,
+			want:   "STARTS_WITH(`page`.`title`, \"A\") AND `page`.`language` IN UNNEST([\"en\", \"ja\"]) AND `page`.`id` > 10",
+		},
+		{
+			name:   "logical",
+			source: `!(page.title == "A") || has(page.comment)`,
+			want:   "NOT (`page`.`title` = \"A\") OR `page`.`comment` IS NOT NULL",
+		},
+		{
+			name:   "unlisted",
+			source: `page.contributor_username.matches("^bot")`,
+			want:   "REGEXP_CONTAINS(`page`.`contributor_username`, \"^bot\")",
+		},
+		{
+			name:         "function",
+			source:       `page.id == 1 || page.language.matches("^e")`,
+			wantOperator: "matches",
+			wantField:    "page.language",
+			wantExpr:     `page.language.matches("^e")`,
+		},
+		{
+			name:         "operator",
+			source:       `page.id <= 10`,
+			wantOperator: "<=",
+			wantField:    "page.id",
+			wantExpr:     `page.id <= 10`,
+		},
+		{
+			name:         "nested",
+			source:       `size(page.title) > 3`,
+			wantOperator: "size",
+			wantField:    "page.title",
+			wantExpr:     `size(page.title)`,
+		},
+		{
+			name:         "has",
+			source:       `has(page.title)`,
+			wantOperator: "has",
+			wantField:    "page.title",
+			wantExpr:     `has(page.title)`,
+		},
+		{
+			name:         "conditional",
+			source:       `(page.id > 0 ? page.language : "").matches("a.*")`,
+			wantOperator: "matches",
+			wantField:    "page.language",
+			wantExpr:     `((page.id > 0) ? page.language : "").matches("a.*")`,
+		},
+		{
+			name:         "listIndex",
+			source:       `[page.language][0].matches("a.*")`,
+			wantOperator: "matches",
+			wantField:    "page.language",
+			wantExpr:     `[page.language][0].matches("a.*")`,
+		},
+		{
+			name:         "mapIndex",
+			source:       `size({"l": page.title}["l"]) > 3`,
+			wantOperator: "size",
+			wantField:    "page.title",
+			wantExpr:     `size({"l": page.title}["l"])`,
+		},
+		{
+			name:         "comprehension",
+			source:       `trigram.cell.exists(c, c.page_count > 1)`,
+			wantOperator: "comprehension",
+			wantField:    "trigram.cell",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ast, issues := env.Compile(tt.source)
+			require.Empty(t, issues)
+
+			got, err := cel2sql.Convert(ast, allowed)
+			if tt.wantOperator != "" {
+				var operatorErr *cel2sql.OperatorNotAllowedError
+				require.True(t, errors.As(err, &operatorErr), "%v", err)
+				assert.Equal(t, tt.wantOperator, operatorErr.Operator)
+				assert.Equal(t, tt.wantField, operatorErr.Field)
+				assert.Equal(t, tt.wantExpr, operatorErr.Expr)
+				assert.NotZero(t, operatorErr.ExprID)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestSplitFilter_allowedOperators(t *testing.T) {
+	env, err := cel2sql.NewEnv(
+		map[string]bigquery.Schema{
+			"wikipedia": test.NewWikipediaTableMetadata().Schema,
+		},
+		cel2sql.TableVariable("page", "wikipedia"),
+	)
+	require.NoError(t, err)
+	ast, issues := env.Compile(`page.id > 10 && page.title.matches("a.*")`)
+	require.Empty(t, issues)
+
+	_, err = cel2sql.SplitFilter(ast, cel2sql.AllowedOperators(map[string][]string{"page.title": {"=="}}))
+	var operatorErr *cel2sql.OperatorNotAllowedError
+	require.True(t, errors.As(err, &operatorErr), "%v", err)
+	assert.Equal(t, "matches", operatorErr.Operator)
+	assert.Equal(t, "page.title", operatorErr.Field)
+}
+
+func TestAllowedOperators_rowPolicy(t *testing.T) {
+	env, err := cel2sql.NewEnv(
+		map[string]bigquery.Schema{
+			"wikipedia": test.NewWikipediaTableMetadata().Schema,
+		},
+		cel2sql.TableVariable("page", "wikipedia"),
+	)
+	require.NoError(t, err)
+	predicate, issues := env.Compile(`page.id == 42`)
+	require.Empty(t, issues)
+	ast, issues := env.Compile(`page.id < 100`)
+	require.Empty(t, issues)
+
+	allowed := cel2sql.AllowedOperators(map[string][]string{"page.id": {"<"}})
+	got, err := cel2sql.Convert(ast, allowed, cel2sql.RowPolicy("page", predicate))
+	require.NoError(t, err)
+	assert.Equal(t, "`page`.`id` = 42 AND `page`.`id` < 100", got)
+
+	ast, issues = env.Compile(`page.id == 100`)
+	require.Empty(t, issues)
+	_, err = cel2sql.Convert(ast, allowed, cel2sql.RowPolicy("page", predicate))
+	var operatorErr *cel2sql.OperatorNotAllowedError
+	require.True(t, errors.As(err, &operatorErr), "%v", err)
+	assert.Equal(t, "page.id", operatorErr.Field)
+}
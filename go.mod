@@ -3,6 +3,7 @@ module github.com/cockscomb/cel2sql
 go 1.16
 
 require (
+	cloud.google.com/go v0.97.0
 	cloud.google.com/go/bigquery v1.25.0
 	github.com/google/cel-go v0.7.3
 	github.com/stretchr/testify v1.7.0
@@ -0,0 +1,272 @@
+package cel2sql
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/civil"
+	"github.com/google/cel-go/cel"
+	"github.com/google/cel-go/checker/decls"
+	exprpb "google.golang.org/genproto/googleapis/api/expr/v1alpha1"
+)
+
+// Keyset is a filter selecting the rows after a cursor in an ordering.
+type Keyset struct {
+	// Filter is the filter of the query AND-ed with the keyset predicate. It is checked in the
+	// environment extended with the cursor parameters, and is converted with QueryParameters.
+	Filter *cel.Ast
+	// Parameters are the values of the cursor parameters by their names, e.g. `cursor_0`.
+	Parameters map[string]interface{}
+}
+
+// KeysetFilter returns the filter selecting the rows of the variable after the cursor, which holds
+// the values of the ordered fields in the last row of the previous page. The predicate is
+// `(a > @cursor_0) OR (a = @cursor_0 AND b > @cursor_1) ...`, and is AND-ed with the filter unless
+// the filter is nil.
+//
+// NULLs are placed as the Nulls of the orderings, which OrderByClause renders explicitly. A NULL
+// cursor value is rendered as IS NULL instead of a parameter. Go integers and float32 in the cursor
+// are widened to int64 and float64.
+//
+// The variable must be a table variable. The top-level columns of RowTable cannot be ordered by a
+// keyset, as CEL cannot test an identifier for NULL as has() does a field.
+func KeysetFilter(env *cel.Env, variable string, orderings []Ordering, cursor []interface{}, filter *cel.Ast) (*Keyset, error) {
+	if len(orderings) == 0 {
+		return nil, fmt.Errorf("keyset requires orderings")
+	}
+	if len(orderings) != len(cursor) {
+		return nil, fmt.Errorf("cursor has %d values for %d orderings", len(cursor), len(orderings))
+	}
+	if variable == "" {
+		return nil, fmt.Errorf("keyset requires a table variable")
+	}
+
+	var declarations []*exprpb.Decl
+	parameters := map[string]interface{}{}
+	fields := make([]string, len(orderings))
+	values := make([]string, len(orderings))
+	for i, ordering := range orderings {
+		checkedExpr, err := compileField(env, variable, ordering.Field)
+		if err != nil {
+			return nil, err
+		}
+		if checkedExpr.Expr.GetSelectExpr() == nil {
+			return nil, fmt.Errorf("field \"%s\" is not a field of a table variable", ordering.Field)
+		}
+		typ := checkedExpr.TypeMap[checkedExpr.Expr.GetId()]
+		if err := validateOrderable(ordering.Field, typ); err != nil {
+			return nil, err
+		}
+		fields[i] = variable + "." + ordering.Field
+		if cursor[i] == nil {
+			continue
+		}
+		value, err := cursorValue(typ, cursor[i])
+		if err != nil {
+			return nil, fmt.Errorf("cursor value of \"%s\": %w", ordering.Field, err)
+		}
+		name := "cursor_" + strconv.Itoa(i)
+		declarations = append(declarations, decls.NewVar(name, typ))
+		parameters[name] = value
+		values[i] = name
+	}
+
+	// The disjunct i matches the rows tied with the cursor before the i-th ordering and after it in
+	// the i-th ordering.
+	var disjuncts []string
+	for i, ordering := range orderings {
+		after := keysetAfter(fields[i], values[i], ordering.Direction, ordering.nulls())
+		if after == "" {
+			continue
+		}
+		conjuncts := make([]string, 0, i+1)
+		for j := 0; j < i; j++ {
+			conjuncts = append(conjuncts, keysetTie(fields[j], values[j]))
+		}
+		conjuncts = append(conjuncts, after)
+		disjuncts = append(disjuncts, "("+strings.Join(conjuncts, " && ")+")")
+	}
+	predicate := "false"
+	if len(disjuncts) > 0 {
+		predicate = strings.Join(disjuncts, " || ")
+	}
+
+	if filter != nil {
+		if filter.ResultType().GetPrimitive() != exprpb.Type_BOOL {
+			return nil, fmt.Errorf("filter must be bool but %s", cel.FormatType(filter.ResultType()))
+		}
+		source, err := cel.AstToString(filter)
+		if err != nil {
+			return nil, err
+		}
+		predicate = "(" + source + ") && (" + predicate + ")"
+	}
+	extended, err := env.Extend(cel.Declarations(declarations...))
+	if err != nil {
+		return nil, err
+	}
+	ast, issues := extended.Compile(predicate)
+	if issues != nil && issues.Err() != nil {
+		return nil, issues.Err()
+	}
+	return &Keyset{Filter: ast, Parameters: parameters}, nil
+}
+
+// keysetAfter returns the condition of the field after the cursor value, which is empty when no
+// value can be after it.
+func keysetAfter(field string, value string, direction Direction, nulls NullOrder) string {
+	operator := " > "
+	if direction == Descending {
+		operator = " < "
+	}
+	switch {
+	case value == "" && nulls == NullsFirst:
+		return "has(" + field + ")"
+	case value == "":
+		return ""
+	case nulls == NullsFirst:
+		return field + operator + value
+	default:
+		return "(" + field + operator + value + " || !has(" + field + "))"
+	}
+}
+
+func keysetTie(field string, value string) string {
+	if value == "" {
+		return "!has(" + field + ")"
+	}
+	return field + " == " + value
+}
+
+// cursorValue coerces the value decoded from a page token to the Go type of the field type, and
+// validates the type of the value.
+func cursorValue(typ *exprpb.Type, value interface{}) (interface{}, error) {
+	value = widenNumber(value)
+	if n, ok := value.(json.Number); ok {
+		switch typ.GetPrimitive() {
+		case exprpb.Type_INT64:
+			return n.Int64()
+		case exprpb.Type_DOUBLE:
+			return n.Float64()
+		}
+	}
+	if s, ok := value.(string); ok {
+		switch {
+		case typ.GetPrimitive() == exprpb.Type_STRING:
+			return s, nil
+		case typ.GetPrimitive() == exprpb.Type_BYTES:
+			return base64.StdEncoding.DecodeString(s)
+		case typ.GetWellKnown() == exprpb.Type_TIMESTAMP:
+			return time.Parse(time.RFC3339Nano, s)
+		case typ.GetAbstractType().GetName() == "DATE":
+			return civil.ParseDate(s)
+		case typ.GetAbstractType().GetName() == "TIME":
+			return civil.ParseTime(s)
+		case typ.GetAbstractType().GetName() == "DATETIME":
+			return civil.ParseDateTime(s)
+		}
+	}
+	var valid bool
+	switch value.(type) {
+	case bool:
+		valid = typ.GetPrimitive() == exprpb.Type_BOOL
+	case int64:
+		valid = typ.GetPrimitive() == exprpb.Type_INT64
+	case float64:
+		valid = typ.GetPrimitive() == exprpb.Type_DOUBLE
+	case []byte:
+		valid = typ.GetPrimitive() == exprpb.Type_BYTES
+	case time.Time:
+		valid = typ.GetWellKnown() == exprpb.Type_TIMESTAMP
+	case civil.Date:
+		valid = typ.GetAbstractType().GetName() == "DATE"
+	case civil.Time:
+		valid = typ.GetAbstractType().GetName() == "TIME"
+	case civil.DateTime:
+		valid = typ.GetAbstractType().GetName() == "DATETIME"
+	}
+	if !valid {
+		return nil, fmt.Errorf("%v is not %s", value, cel.FormatType(typ))
+	}
+	return value, nil
+}
+
+// widenNumber widens the Go integers to int64 and float32 to float64, which are the Go types of
+// the numbers of CEL.
+func widenNumber(value interface{}) interface{} {
+	switch v := value.(type) {
+	case int:
+		return int64(v)
+	case int8:
+		return int64(v)
+	case int16:
+		return int64(v)
+	case int32:
+		return int64(v)
+	case uint8:
+		return int64(v)
+	case uint16:
+		return int64(v)
+	case uint32:
+		return int64(v)
+	case float32:
+		return float64(v)
+	}
+	return value
+}
+
+type pageToken struct {
+	Fields []string      `json:"f"`
+	Values []interface{} `json:"v"`
+}
+
+// EncodePageToken returns an opaque page token of the cursor in the orderings, which holds the
+// values of the ordered fields in the last row of a page.
+func EncodePageToken(orderings []Ordering, cursor []interface{}) (string, error) {
+	if len(orderings) != len(cursor) {
+		return "", fmt.Errorf("cursor has %d values for %d orderings", len(cursor), len(orderings))
+	}
+	b, err := json.Marshal(&pageToken{Fields: orderingKeys(orderings), Values: cursor})
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// DecodePageToken returns the cursor of the page token, which must have been encoded for the same
+// orderings. The values are coerced to the field types by KeysetFilter.
+func DecodePageToken(token string, orderings []Ordering) ([]interface{}, error) {
+	b, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, fmt.Errorf("invalid page token")
+	}
+	decoder := json.NewDecoder(bytes.NewReader(b))
+	decoder.UseNumber()
+	var t pageToken
+	if err := decoder.Decode(&t); err != nil {
+		return nil, fmt.Errorf("invalid page token")
+	}
+	keys := orderingKeys(orderings)
+	if len(t.Fields) != len(keys) || len(t.Values) != len(keys) {
+		return nil, fmt.Errorf("page token does not match the orderings")
+	}
+	for i, key := range keys {
+		if t.Fields[i] != key {
+			return nil, fmt.Errorf("page token does not match the orderings")
+		}
+	}
+	return t.Values, nil
+}
+
+func orderingKeys(orderings []Ordering) []string {
+	keys := make([]string, len(orderings))
+	for i, ordering := range orderings {
+		keys[i] = ordering.Field + " " + ordering.Direction.String() + " " + ordering.nulls().String()
+	}
+	return keys
+}
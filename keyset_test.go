@@ -0,0 +1,223 @@
+package cel2sql_test
+
+import (
+	"testing"
+	"time"
+
+	"cloud.google.com/go/bigquery"
+	"cloud.google.com/go/civil"
+	"github.com/google/cel-go/cel"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/cockscomb/cel2sql"
+)
+
+func TestKeysetFilter(t *testing.T) {
+	env, err := cel2sql.NewEnv(
+		map[string]bigquery.Schema{
+			"books": {
+				{Name: "title", Type: bigquery.StringFieldType},
+				{Name: "id", Type: bigquery.IntegerFieldType},
+				{Name: "create_time", Type: bigquery.TimestampFieldType},
+				{Name: "publish_date", Type: bigquery.DateFieldType},
+				{Name: "tags", Type: bigquery.StringFieldType, Repeated: true},
+			},
+		},
+		cel2sql.TableVariable("book", "books"),
+	)
+	require.NoError(t, err)
+	compile := func(source string) *cel.Ast {
+		ast, issues := env.Compile(source)
+		require.Empty(t, issues)
+		return ast
+	}
+
+	type args struct {
+		orderings []cel2sql.Ordering
+		cursor    []interface{}
+		filter    *cel.Ast
+	}
+	tests := []struct {
+		name           string
+		args           args
+		want           string
+		wantParameters map[string]interface{}
+		wantErr        bool
+	}{
+		{
+			name: "single",
+			args: args{
+				orderings: []cel2sql.Ordering{{Field: "id"}},
+				cursor:    []interface{}{int64(10)},
+			},
+			want:           "`book`.`id` > @cursor_0",
+			wantParameters: map[string]interface{}{"cursor_0": int64(10)},
+		},
+		{
+			name: "multiple",
+			args: args{
+				orderings: []cel2sql.Ordering{
+					{Field: "title", Direction: cel2sql.Ascending},
+					{Field: "id", Direction: cel2sql.Descending},
+				},
+				cursor: []interface{}{"Go", int64(10)},
+			},
+			want:           "`book`.`title` > @cursor_0 OR `book`.`title` = @cursor_0 AND (`book`.`id` < @cursor_1 OR `book`.`id` IS NULL)",
+			wantParameters: map[string]interface{}{"cursor_0": "Go", "cursor_1": int64(10)},
+		},
+		{
+			name: "nullAscending",
+			args: args{
+				orderings: []cel2sql.Ordering{
+					{Field: "title", Direction: cel2sql.Ascending},
+					{Field: "id", Direction: cel2sql.Ascending},
+				},
+				cursor: []interface{}{nil, int64(10)},
+			},
+			want:           "`book`.`title` IS NOT NULL OR `book`.`title` IS NULL AND `book`.`id` > @cursor_1",
+			wantParameters: map[string]interface{}{"cursor_1": int64(10)},
+		},
+		{
+			name: "nullDescending",
+			args: args{
+				orderings: []cel2sql.Ordering{{Field: "title", Direction: cel2sql.Descending}},
+				cursor:    []interface{}{nil},
+			},
+			want:           "FALSE",
+			wantParameters: map[string]interface{}{},
+		},
+		{
+			name: "nullsLast",
+			args: args{
+				orderings: []cel2sql.Ordering{{Field: "id", Direction: cel2sql.Ascending, Nulls: cel2sql.NullsLast}},
+				cursor:    []interface{}{int64(10)},
+			},
+			want:           "`book`.`id` > @cursor_0 OR `book`.`id` IS NULL",
+			wantParameters: map[string]interface{}{"cursor_0": int64(10)},
+		},
+		{
+			name: "nullsFirst",
+			args: args{
+				orderings: []cel2sql.Ordering{
+					{Field: "title", Direction: cel2sql.Descending, Nulls: cel2sql.NullsFirst},
+					{Field: "id", Direction: cel2sql.Descending, Nulls: cel2sql.NullsFirst},
+				},
+				cursor: []interface{}{nil, int64(10)},
+			},
+			want:           "`book`.`title` IS NOT NULL OR `book`.`title` IS NULL AND `book`.`id` < @cursor_1",
+			wantParameters: map[string]interface{}{"cursor_1": int64(10)},
+		},
+		{
+			name: "filter",
+			args: args{
+				orderings: []cel2sql.Ordering{{Field: "id"}},
+				cursor:    []interface{}{int64(10)},
+				filter:    compile(`book.title.startsWith("G") || book.id < 5`),
+			},
+			want:           "(STARTS_WITH(`book`.`title`, \"G\") OR `book`.`id` < 5) AND `book`.`id` > @cursor_0",
+			wantParameters: map[string]interface{}{"cursor_0": int64(10)},
+		},
+		{
+			name: "repeated",
+			args: args{
+				orderings: []cel2sql.Ordering{{Field: "tags"}},
+				cursor:    []interface{}{"a"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "typeMismatch",
+			args: args{
+				orderings: []cel2sql.Ordering{{Field: "id"}},
+				cursor:    []interface{}{"10"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "goInt",
+			args: args{
+				orderings: []cel2sql.Ordering{{Field: "id"}},
+				cursor:    []interface{}{10},
+			},
+			want:           "`book`.`id` > @cursor_0",
+			wantParameters: map[string]interface{}{"cursor_0": int64(10)},
+		},
+		{
+			name: "cursorLength",
+			args: args{
+				orderings: []cel2sql.Ordering{{Field: "id"}},
+				cursor:    []interface{}{int64(10), int64(20)},
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := cel2sql.KeysetFilter(env, "book", tt.args.orderings, tt.args.cursor, tt.args.filter)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			sql, err := cel2sql.Convert(got.Filter, cel2sql.QueryParameters(got.Parameters))
+			if assert.NoError(t, err) {
+				assert.Equal(t, tt.want, sql)
+				assert.Equal(t, tt.wantParameters, got.Parameters)
+			}
+		})
+	}
+}
+
+func TestKeysetFilter_rowTable(t *testing.T) {
+	env, err := cel2sql.NewEnv(
+		map[string]bigquery.Schema{
+			"books": {{Name: "id", Type: bigquery.IntegerFieldType}},
+		},
+		cel2sql.RowTable("books"),
+	)
+	require.NoError(t, err)
+
+	_, err = cel2sql.KeysetFilter(env, "", []cel2sql.Ordering{{Field: "id"}}, []interface{}{int64(10)}, nil)
+	assert.EqualError(t, err, "keyset requires a table variable")
+}
+
+func TestPageToken(t *testing.T) {
+	env, err := cel2sql.NewEnv(
+		map[string]bigquery.Schema{
+			"books": {
+				{Name: "id", Type: bigquery.IntegerFieldType},
+				{Name: "create_time", Type: bigquery.TimestampFieldType},
+				{Name: "publish_date", Type: bigquery.DateFieldType},
+			},
+		},
+		cel2sql.TableVariable("book", "books"),
+	)
+	require.NoError(t, err)
+
+	orderings := []cel2sql.Ordering{
+		{Field: "create_time", Direction: cel2sql.Descending},
+		{Field: "publish_date"},
+		{Field: "id"},
+	}
+	createTime := time.Date(2021, 1, 2, 3, 4, 5, 6, time.UTC)
+	token, err := cel2sql.EncodePageToken(orderings, []interface{}{createTime, civil.Date{Year: 2021, Month: 1, Day: 2}, int64(1) << 60})
+	require.NoError(t, err)
+
+	cursor, err := cel2sql.DecodePageToken(token, orderings)
+	require.NoError(t, err)
+	keyset, err := cel2sql.KeysetFilter(env, "book", orderings, cursor, nil)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{
+		"cursor_0": createTime,
+		"cursor_1": civil.Date{Year: 2021, Month: 1, Day: 2},
+		"cursor_2": int64(1) << 60,
+	}, keyset.Parameters)
+
+	_, err = cel2sql.DecodePageToken(token, orderings[:2])
+	assert.Error(t, err)
+	_, err = cel2sql.DecodePageToken(token, []cel2sql.Ordering{orderings[0], orderings[2], orderings[1]})
+	assert.Error(t, err)
+	_, err = cel2sql.DecodePageToken("not a token", orderings)
+	assert.Error(t, err)
+}
@@ -0,0 +1,199 @@
+package cel2sql
+
+import (
+	"fmt"
+
+	"github.com/google/cel-go/common/overloads"
+	exprpb "google.golang.org/genproto/googleapis/api/expr/v1alpha1"
+)
+
+// Limit is a kind of the limits on the complexity of the expressions and the SQL.
+type Limit int
+
+const (
+	// LimitNodes limits the number of the nodes of the expression.
+	LimitNodes Limit = iota
+	// LimitDepth limits the nesting depth of the expression.
+	LimitDepth
+	// LimitListLength limits the number of the elements of a list literal, e.g. of `in`.
+	LimitListLength
+	// LimitStringLength limits the length of a string or bytes literal in bytes.
+	LimitStringLength
+	// LimitRegexes limits the number of the regular expressions matched by `matches`.
+	LimitRegexes
+	// LimitSQLLength limits the length of the SQL in bytes.
+	LimitSQLLength
+)
+
+func (l Limit) String() string {
+	switch l {
+	case LimitNodes:
+		return "nodes"
+	case LimitDepth:
+		return "nesting depth"
+	case LimitListLength:
+		return "list length"
+	case LimitStringLength:
+		return "string length"
+	case LimitRegexes:
+		return "regular expressions"
+	case LimitSQLLength:
+		return "SQL length"
+	}
+	return fmt.Sprintf("Limit(%d)", int(l))
+}
+
+// LimitError reports that the expression or the SQL exceeds the limit.
+type LimitError struct {
+	// Location is the location of the sub-expression exceeding the limit, which is unknown for
+	// the limit on the nodes.
+	Location
+	Limit Limit
+	Max   int
+}
+
+func (e *LimitError) Error() string {
+	return fmt.Sprintf("%slimit of %s exceeded: %d", e.prefix(), e.Limit, e.Max)
+}
+
+type limits struct {
+	nodes        int
+	depth        int
+	listLength   int
+	stringLength int
+	regexes      int
+	sqlLength    int
+}
+
+// MaxNodes limits the number of the nodes of the expression. Zero, the default, is unlimited as
+// well as the other limits.
+func MaxNodes(n int) ConvertOption {
+	return func(con *converter) {
+		con.limits.nodes = n
+	}
+}
+
+// MaxDepth limits the nesting depth of the expression, e.g. 3 for `a + (b + c)`.
+func MaxDepth(n int) ConvertOption {
+	return func(con *converter) {
+		con.limits.depth = n
+	}
+}
+
+// MaxListLength limits the number of the elements of each list literal, e.g. the list of `in`.
+func MaxListLength(n int) ConvertOption {
+	return func(con *converter) {
+		con.limits.listLength = n
+	}
+}
+
+// MaxStringLength limits the length of each string or bytes literal in bytes.
+func MaxStringLength(n int) ConvertOption {
+	return func(con *converter) {
+		con.limits.stringLength = n
+	}
+}
+
+// MaxRegexes limits the number of the calls of `matches` in the expression.
+func MaxRegexes(n int) ConvertOption {
+	return func(con *converter) {
+		con.limits.regexes = n
+	}
+}
+
+// MaxSQLLength limits the length of the converted SQL in bytes, which includes the predicates of
+// the row policies and the statement of BuildQuery. The conversion stops as soon as the SQL
+// exceeds the limit.
+func MaxSQLLength(n int) ConvertOption {
+	return func(con *converter) {
+		con.limits.sqlLength = n
+	}
+}
+
+// checkLimits returns *LimitError if the expression exceeds the limits, which are checked before
+// the conversion except that on the SQL. The depth is checked first, so that a deeply nested
+// expression is rejected before any pass walks the whole of it.
+func (con *converter) checkLimits(expr *exprpb.Expr) error {
+	if con.limits.depth > 0 {
+		if err := con.checkDepth(expr, 1); err != nil {
+			return err
+		}
+	}
+	var err error
+	nodes, regexes := 0, 0
+	walkExpr(expr, func(e *exprpb.Expr) {
+		if err != nil {
+			return
+		}
+		nodes++
+		if exceeds(nodes, con.limits.nodes) {
+			err = &LimitError{Limit: LimitNodes, Max: con.limits.nodes}
+			return
+		}
+		switch e.ExprKind.(type) {
+		case *exprpb.Expr_ListExpr:
+			if exceeds(len(e.GetListExpr().GetElements()), con.limits.listLength) {
+				err = &LimitError{Location: Location{ExprID: e.GetId()}, Limit: LimitListLength, Max: con.limits.listLength}
+			}
+		case *exprpb.Expr_ConstExpr:
+			c := e.GetConstExpr()
+			if exceeds(len(c.GetStringValue())+len(c.GetBytesValue()), con.limits.stringLength) {
+				err = &LimitError{Location: Location{ExprID: e.GetId()}, Limit: LimitStringLength, Max: con.limits.stringLength}
+			}
+		case *exprpb.Expr_CallExpr:
+			if e.GetCallExpr().GetFunction() != overloads.Matches {
+				return
+			}
+			regexes++
+			if exceeds(regexes, con.limits.regexes) {
+				err = &LimitError{Location: Location{ExprID: e.GetId()}, Limit: LimitRegexes, Max: con.limits.regexes}
+			}
+		}
+	})
+	return err
+}
+
+// checkDepth returns *LimitError if the expression at the depth is nested beyond the limit. It does
+// not descend below the limit.
+func (con *converter) checkDepth(expr *exprpb.Expr, depth int) error {
+	if expr == nil {
+		return nil
+	}
+	if exceeds(depth, con.limits.depth) {
+		return &LimitError{Location: Location{ExprID: expr.GetId()}, Limit: LimitDepth, Max: con.limits.depth}
+	}
+	for _, child := range childExprs(expr) {
+		if err := con.checkDepth(child, depth+1); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// enterVisit increments the depth of the visit, returning *LimitError if the depth or the SQL
+// exceeds the limits.
+func (con *converter) enterVisit(expr *exprpb.Expr) error {
+	con.depth++
+	if exceeds(con.depth, con.limits.depth) {
+		return &LimitError{Location: Location{ExprID: expr.GetId()}, Limit: LimitDepth, Max: con.limits.depth}
+	}
+	return con.checkSQLLength()
+}
+
+func (con *converter) checkSQLLength() error {
+	return con.checkLength(con.str.Len())
+}
+
+// checkLength returns *LimitError if the length of the SQL exceeds the limit, e.g. of the statement
+// built from the converted expressions.
+func (con *converter) checkLength(length int) error {
+	if exceeds(length, con.limits.sqlLength) {
+		return &LimitError{Limit: LimitSQLLength, Max: con.limits.sqlLength}
+	}
+	return nil
+}
+
+// exceeds reports whether n exceeds the limit, which is unlimited if zero.
+func exceeds(n int, limit int) bool {
+	return limit > 0 && n > limit
+}
@@ -0,0 +1,185 @@
+package cel2sql_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/google/cel-go/cel"
+	"github.com/google/cel-go/checker/decls"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/cockscomb/cel2sql"
+)
+
+func TestConvert_limits(t *testing.T) {
+	env, err := cel.NewEnv(
+		cel.Declarations(
+			decls.NewVar("name", decls.String),
+			decls.NewVar("age", decls.Int),
+		),
+	)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name      string
+		source    string
+		opts      []cel2sql.ConvertOption
+		want      string
+		wantLimit cel2sql.Limit
+	}{
+		{
+			name:   "withinLimits",
+			source: `name.matches("^a") && name in ["a", "b"] && age + (age + 1) > 2`,
+			opts: []cel2sql.ConvertOption{
+				cel2sql.MaxNodes(17),
+				cel2sql.MaxDepth(5),
+				cel2sql.MaxListLength(2),
+				cel2sql.MaxStringLength(2),
+				cel2sql.MaxRegexes(1),
+				cel2sql.MaxSQLLength(100),
+			},
+			want: "REGEXP_CONTAINS(`name`, \"^a\") AND `name` IN UNNEST([\"a\", \"b\"]) AND `age` + (`age` + 1) > 2",
+		},
+		{
+			name:      "nodes",
+			source:    `age + age + age > 0`,
+			opts:      []cel2sql.ConvertOption{cel2sql.MaxNodes(6)},
+			wantLimit: cel2sql.LimitNodes,
+		},
+		{
+			name:      "depth",
+			source:    `age + (age + (age + (age + 1))) > 0`,
+			opts:      []cel2sql.ConvertOption{cel2sql.MaxDepth(4)},
+			wantLimit: cel2sql.LimitDepth,
+		},
+		{
+			name:   "depthBeforeOtherChecks",
+			source: `(((age + 1) + 1) + 1) > 0 || name.matches("^a")`,
+			opts: []cel2sql.ConvertOption{
+				cel2sql.MaxDepth(4),
+				cel2sql.AllowedOperators(map[string][]string{"name": {"=="}}),
+			},
+			wantLimit: cel2sql.LimitDepth,
+		},
+		{
+			name:      "listLength",
+			source:    `name in ["a", "b", "c"]`,
+			opts:      []cel2sql.ConvertOption{cel2sql.MaxListLength(2)},
+			wantLimit: cel2sql.LimitListLength,
+		},
+		{
+			name:      "stringLength",
+			source:    `name == "` + strings.Repeat("a", 11) + `"`,
+			opts:      []cel2sql.ConvertOption{cel2sql.MaxStringLength(10)},
+			wantLimit: cel2sql.LimitStringLength,
+		},
+		{
+			name:      "regexes",
+			source:    `name.matches("^a") || name.matches("^b")`,
+			opts:      []cel2sql.ConvertOption{cel2sql.MaxRegexes(1)},
+			wantLimit: cel2sql.LimitRegexes,
+		},
+		{
+			name:      "sqlLength",
+			source:    `name == "abc" || name == "def"`,
+			opts:      []cel2sql.ConvertOption{cel2sql.MaxSQLLength(20)},
+			wantLimit: cel2sql.LimitSQLLength,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ast, issues := env.Compile(tt.source)
+			require.Empty(t, issues)
+
+			got, err := cel2sql.Convert(ast, tt.opts...)
+			if tt.want == "" {
+				var limitErr *cel2sql.LimitError
+				require.True(t, errors.As(err, &limitErr), "%v", err)
+				assert.Equal(t, tt.wantLimit, limitErr.Limit)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestSplitFilter_limits(t *testing.T) {
+	env, err := cel.NewEnv(
+		cel.Declarations(
+			decls.NewVar("name", decls.String),
+			decls.NewVar("age", decls.Int),
+		),
+	)
+	require.NoError(t, err)
+	ast, issues := env.Compile(`age > 10 && name == "abcdefghij"`)
+	require.Empty(t, issues)
+
+	_, err = cel2sql.SplitFilter(ast, cel2sql.MaxStringLength(3))
+	var limitErr *cel2sql.LimitError
+	require.True(t, errors.As(err, &limitErr), "%v", err)
+	assert.Equal(t, cel2sql.LimitStringLength, limitErr.Limit)
+}
+
+func TestSplitFilter_limitsOfWholeFilter(t *testing.T) {
+	env, err := cel.NewEnv(
+		cel.Declarations(
+			decls.NewVar("name", decls.String),
+			decls.NewVar("age", decls.Int),
+		),
+	)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name      string
+		source    string
+		opt       cel2sql.ConvertOption
+		wantLimit cel2sql.Limit
+	}{
+		{
+			name:      "nodes",
+			source:    `age > 1 && age > 2 && age > 3 && age > 4`,
+			opt:       cel2sql.MaxNodes(5),
+			wantLimit: cel2sql.LimitNodes,
+		},
+		{
+			name:      "regexes",
+			source:    `name.matches("^a") && name.matches("^b")`,
+			opt:       cel2sql.MaxRegexes(1),
+			wantLimit: cel2sql.LimitRegexes,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ast, issues := env.Compile(tt.source)
+			require.Empty(t, issues)
+
+			_, err := cel2sql.SplitFilter(ast, tt.opt)
+			var limitErr *cel2sql.LimitError
+			require.True(t, errors.As(err, &limitErr), "%v", err)
+			assert.Equal(t, tt.wantLimit, limitErr.Limit)
+		})
+	}
+}
+
+func TestConvertFilter_sqlLength(t *testing.T) {
+	env, err := cel.NewEnv(
+		cel.Declarations(
+			decls.NewVar("name", decls.String),
+			decls.NewVar("age", decls.Int),
+		),
+	)
+	require.NoError(t, err)
+	predicate, issues := env.Compile(`age > 10`)
+	require.Empty(t, issues)
+	ast, issues := env.Compile(`name == "abc"`)
+	require.Empty(t, issues)
+
+	// each of the conjuncts is within the limit, but the conjunction is not.
+	_, err = cel2sql.ConvertFilter(ast, cel2sql.RowPolicy("age", predicate), cel2sql.MaxSQLLength(20))
+	var limitErr *cel2sql.LimitError
+	require.True(t, errors.As(err, &limitErr), "%v", err)
+	assert.Equal(t, cel2sql.LimitSQLLength, limitErr.Limit)
+}
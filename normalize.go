@@ -0,0 +1,195 @@
+package cel2sql
+
+import (
+	"fmt"
+
+	"github.com/google/cel-go/cel"
+	"github.com/google/cel-go/common/operators"
+	"github.com/google/cel-go/parser"
+	exprpb "google.golang.org/genproto/googleapis/api/expr/v1alpha1"
+)
+
+// NormalForm is the form of a boolean expression produced by Normalize.
+type NormalForm int
+
+const (
+	// ConjunctiveNormalForm is a conjunction of disjunctions, e.g. `(a || b) && (c || d)`.
+	ConjunctiveNormalForm NormalForm = iota
+	// DisjunctiveNormalForm is a disjunction of conjunctions, e.g. `(a && b) || (c && d)`.
+	DisjunctiveNormalForm
+)
+
+// DefaultMaxClauses is the default limit of the number of clauses produced by Normalize.
+const DefaultMaxClauses = 256
+
+// NormalizeOption configures Normalize.
+type NormalizeOption func(*normalizer)
+
+// MaxClauses limits the number of clauses produced by Normalize, which may grow exponentially with
+// the size of the expression.
+func MaxClauses(n int) NormalizeOption {
+	return func(normalizer *normalizer) {
+		normalizer.maxClauses = n
+	}
+}
+
+// ClauseLimitError reports that the normal form would have more clauses than the limit.
+type ClauseLimitError struct {
+	Limit int
+}
+
+func (e *ClauseLimitError) Error() string {
+	return fmt.Sprintf("normal form exceeds the limit of %d clauses", e.Limit)
+}
+
+// Normalize rewrites the checked boolean AST into the normal form. Negations are pushed down
+// through `&&` and `||` by De Morgan's laws, and double negations are eliminated. The other
+// sub-expressions, e.g. comparisons, are the literals of the clauses. Duplicate literals in a
+// clause and duplicate clauses are removed.
+func Normalize(ast *cel.Ast, form NormalForm, opts ...NormalizeOption) (*cel.Ast, error) {
+	if ast.ResultType().GetPrimitive() != exprpb.Type_BOOL {
+		return nil, fmt.Errorf("expression must be bool but %s", cel.FormatType(ast.ResultType()))
+	}
+	checkedExpr, err := cel.AstToCheckedExpr(ast)
+	if err != nil {
+		return nil, err
+	}
+	n := newNormalizer(checkedExpr, form)
+	for _, opt := range opts {
+		opt(n)
+	}
+	clauses, err := n.clauses(checkedExpr.GetExpr(), false)
+	if err != nil {
+		return nil, err
+	}
+	outer, inner := operators.LogicalAnd, operators.LogicalOr
+	if form == DisjunctiveNormalForm {
+		outer, inner = inner, outer
+	}
+	exprs := make([]*exprpb.Expr, len(clauses))
+	for i, clause := range clauses {
+		exprs[i] = n.factory.newJunction(inner, clause)
+	}
+	return cel.CheckedExprToAst(n.factory.checked(n.factory.newJunction(outer, exprs))), nil
+}
+
+type normalizer struct {
+	factory    *exprFactory
+	form       NormalForm
+	maxClauses int
+}
+
+func newNormalizer(checkedExpr *exprpb.CheckedExpr, form NormalForm) *normalizer {
+	return &normalizer{
+		factory:    newExprFactory(checkedExpr),
+		form:       form,
+		maxClauses: DefaultMaxClauses,
+	}
+}
+
+// clauses returns the clauses of the expression, negated if negated is true. A clause is a
+// disjunction of literals in CNF, or a conjunction of literals in DNF.
+func (n *normalizer) clauses(expr *exprpb.Expr, negated bool) ([][]*exprpb.Expr, error) {
+	c := expr.GetCallExpr()
+	switch c.GetFunction() {
+	case operators.LogicalNot:
+		return n.clauses(c.GetArgs()[0], !negated)
+	case operators.LogicalAnd, operators.LogicalOr:
+		lhs, err := n.clauses(c.GetArgs()[0], negated)
+		if err != nil {
+			return nil, err
+		}
+		rhs, err := n.clauses(c.GetArgs()[1], negated)
+		if err != nil {
+			return nil, err
+		}
+		// `&&` concatenates the clauses of CNF, and `||` distributes them. Negation swaps them.
+		conjunction := c.GetFunction() == operators.LogicalAnd
+		if (conjunction != negated) == (n.form == ConjunctiveNormalForm) {
+			return n.limit(n.union(lhs, rhs))
+		}
+		return n.product(lhs, rhs)
+	}
+	if isBoolLiteral(expr) {
+		value := expr.GetConstExpr().GetBoolValue() != negated
+		// true is the empty CNF and the DNF of an empty clause, and vice versa.
+		if value == (n.form == ConjunctiveNormalForm) {
+			return [][]*exprpb.Expr{}, nil
+		}
+		return [][]*exprpb.Expr{{}}, nil
+	}
+	if negated {
+		expr = n.factory.newNot(expr)
+	}
+	return [][]*exprpb.Expr{{expr}}, nil
+}
+
+func (n *normalizer) union(lhs, rhs [][]*exprpb.Expr) [][]*exprpb.Expr {
+	seen := map[string]bool{}
+	var clauses [][]*exprpb.Expr
+	for _, clause := range append(append([][]*exprpb.Expr{}, lhs...), rhs...) {
+		key := n.clauseKey(clause)
+		if key != "" && seen[key] {
+			continue
+		}
+		seen[key] = true
+		clauses = append(clauses, clause)
+	}
+	return clauses
+}
+
+// product distributes the clauses, e.g. `(a && b) || c` as `(a || c) && (b || c)` in CNF.
+func (n *normalizer) product(lhs, rhs [][]*exprpb.Expr) ([][]*exprpb.Expr, error) {
+	if len(lhs)*len(rhs) > n.maxClauses {
+		return nil, &ClauseLimitError{Limit: n.maxClauses}
+	}
+	var clauses [][]*exprpb.Expr
+	for _, l := range lhs {
+		for _, r := range rhs {
+			clauses = append(clauses, n.dedupe(append(append([]*exprpb.Expr{}, l...), r...)))
+		}
+	}
+	return n.limit(n.union(clauses, nil))
+}
+
+func (n *normalizer) limit(clauses [][]*exprpb.Expr) ([][]*exprpb.Expr, error) {
+	if len(clauses) > n.maxClauses {
+		return nil, &ClauseLimitError{Limit: n.maxClauses}
+	}
+	return clauses, nil
+}
+
+func (n *normalizer) dedupe(literals []*exprpb.Expr) []*exprpb.Expr {
+	seen := map[string]bool{}
+	var deduped []*exprpb.Expr
+	for _, literal := range literals {
+		key := n.literalKey(literal)
+		if key != "" && seen[key] {
+			continue
+		}
+		seen[key] = true
+		deduped = append(deduped, literal)
+	}
+	return deduped
+}
+
+// literalKey returns the source of the literal to compare literals, or empty if it is unknown.
+func (n *normalizer) literalKey(literal *exprpb.Expr) string {
+	source, err := parser.Unparse(literal, n.factory.checkedExpr.GetSourceInfo())
+	if err != nil {
+		return ""
+	}
+	return source
+}
+
+func (n *normalizer) clauseKey(clause []*exprpb.Expr) string {
+	key := "("
+	for _, literal := range clause {
+		k := n.literalKey(literal)
+		if k == "" {
+			return ""
+		}
+		key += k + ";"
+	}
+	return key + ")"
+}
@@ -0,0 +1,127 @@
+package cel2sql_test
+
+import (
+	"errors"
+	"testing"
+
+	"cloud.google.com/go/bigquery"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/cockscomb/cel2sql"
+	"github.com/cockscomb/cel2sql/test"
+)
+
+func TestNormalize(t *testing.T) {
+	env, err := cel2sql.NewEnv(
+		map[string]bigquery.Schema{
+			"wikipedia": test.NewWikipediaTableMetadata().Schema,
+		},
+		cel2sql.TableVariable("page", "wikipedia"),
+	)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name    string
+		source  string
+		form    cel2sql.NormalForm
+		want    string
+		wantErr bool
+	}{
+		{
+			name:   "cnfDistribute",
+			source: `page.id > 1 && page.id < 10 || page.is_bot`,
+			form:   cel2sql.ConjunctiveNormalForm,
+			want:   "(`page`.`id` > 1 OR `page`.`is_bot`) AND (`page`.`id` < 10 OR `page`.`is_bot`)",
+		},
+		{
+			name:   "dnfDistribute",
+			source: `(page.id > 1 || page.is_bot) && page.is_minor`,
+			form:   cel2sql.DisjunctiveNormalForm,
+			want:   "`page`.`id` > 1 AND `page`.`is_minor` OR `page`.`is_bot` AND `page`.`is_minor`",
+		},
+		{
+			name:   "deMorgan",
+			source: `!(page.id > 1 && !(page.is_bot || page.is_minor))`,
+			form:   cel2sql.ConjunctiveNormalForm,
+			want:   "NOT (`page`.`id` > 1) OR `page`.`is_bot` OR `page`.`is_minor`",
+		},
+		{
+			name:   "deMorganDNF",
+			source: `!(page.is_bot || page.is_minor)`,
+			form:   cel2sql.DisjunctiveNormalForm,
+			want:   "NOT `page`.`is_bot` AND NOT `page`.`is_minor`",
+		},
+		{
+			name:   "duplicates",
+			source: `(page.is_bot || page.is_bot) && (page.is_bot || page.is_bot)`,
+			form:   cel2sql.ConjunctiveNormalForm,
+			want:   "`page`.`is_bot`",
+		},
+		{
+			name:   "constants",
+			source: `page.is_bot || false`,
+			form:   cel2sql.ConjunctiveNormalForm,
+			want:   "`page`.`is_bot`",
+		},
+		{
+			name:   "true",
+			source: `page.is_bot || !false`,
+			form:   cel2sql.ConjunctiveNormalForm,
+			want:   "TRUE",
+		},
+		{
+			name:   "false",
+			source: `page.is_bot && false`,
+			form:   cel2sql.DisjunctiveNormalForm,
+			want:   "FALSE",
+		},
+		{
+			name:    "notBool",
+			source:  `page.id`,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ast, issues := env.Compile(tt.source)
+			require.Empty(t, issues)
+			normalized, err := cel2sql.Normalize(ast, tt.form)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			got, err := cel2sql.Convert(normalized)
+			if assert.NoError(t, err) {
+				assert.Equal(t, tt.want, got)
+			}
+		})
+	}
+}
+
+func TestNormalize_maxClauses(t *testing.T) {
+	env, err := cel2sql.NewEnv(
+		map[string]bigquery.Schema{
+			"wikipedia": test.NewWikipediaTableMetadata().Schema,
+		},
+		cel2sql.TableVariable("page", "wikipedia"),
+	)
+	require.NoError(t, err)
+	// The CNF of the disjunction of n conjunctions of 2 literals has 2^n clauses.
+	ast, issues := env.Compile(`page.id == 1 && page.wp_namespace == 1 ||
+		page.id == 2 && page.wp_namespace == 2 ||
+		page.id == 3 && page.wp_namespace == 3 ||
+		page.id == 4 && page.wp_namespace == 4`)
+	require.Empty(t, issues)
+
+	_, err = cel2sql.Normalize(ast, cel2sql.ConjunctiveNormalForm, cel2sql.MaxClauses(16))
+	assert.NoError(t, err)
+	_, err = cel2sql.Normalize(ast, cel2sql.ConjunctiveNormalForm, cel2sql.MaxClauses(15))
+	var limitError *cel2sql.ClauseLimitError
+	if assert.True(t, errors.As(err, &limitError)) {
+		assert.Equal(t, 15, limitError.Limit)
+	}
+	_, err = cel2sql.Normalize(ast, cel2sql.DisjunctiveNormalForm, cel2sql.MaxClauses(4))
+	assert.NoError(t, err)
+}
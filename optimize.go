@@ -0,0 +1,231 @@
+package cel2sql
+
+import (
+	"github.com/google/cel-go/cel"
+	"github.com/google/cel-go/checker"
+	"github.com/google/cel-go/common/operators"
+	"github.com/google/cel-go/common/overloads"
+	"github.com/google/cel-go/common/types"
+	"github.com/google/cel-go/common/types/ref"
+	exprpb "google.golang.org/genproto/googleapis/api/expr/v1alpha1"
+)
+
+// Optimize returns the checked AST simplified before the conversion, which renders smaller SQL.
+//
+//   - Calls of the standard functions with constant arguments are folded, e.g. `1 + 2` as `3`.
+//   - Boolean operators with constant operands are simplified, e.g. `true && x` as `x`.
+//   - Double negations are eliminated, e.g. `!!x` as `x`.
+//   - `x in [y]` is rewritten as `x == y`, and `x in []` as `false`.
+//
+// The whole AST is simplified, including the entries of map literals and the bodies of
+// comprehensions. Calls evaluated to errors, e.g. `1 / 0`, are left as is.
+func Optimize(ast *cel.Ast) (*cel.Ast, error) {
+	checkedExpr, err := cel.AstToCheckedExpr(ast)
+	if err != nil {
+		return nil, err
+	}
+	return cel.CheckedExprToAst(optimize(checkedExpr)), nil
+}
+
+func optimize(checkedExpr *exprpb.CheckedExpr) *exprpb.CheckedExpr {
+	o := &optimizer{
+		checkedExpr:  checkedExpr,
+		referenceMap: make(map[int64]*exprpb.Reference, len(checkedExpr.GetReferenceMap())),
+	}
+	for id, reference := range checkedExpr.GetReferenceMap() {
+		o.referenceMap[id] = reference
+	}
+	return &exprpb.CheckedExpr{
+		ReferenceMap: o.referenceMap,
+		TypeMap:      checkedExpr.GetTypeMap(),
+		SourceInfo:   checkedExpr.GetSourceInfo(),
+		ExprVersion:  checkedExpr.GetExprVersion(),
+		Expr:         o.optimize(checkedExpr.GetExpr()),
+	}
+}
+
+type optimizer struct {
+	checkedExpr  *exprpb.CheckedExpr
+	referenceMap map[int64]*exprpb.Reference
+}
+
+func (o *optimizer) optimize(expr *exprpb.Expr) *exprpb.Expr {
+	switch kind := expr.ExprKind.(type) {
+	case *exprpb.Expr_CallExpr:
+		c := kind.CallExpr
+		call := &exprpb.Expr_Call{Function: c.GetFunction()}
+		if c.GetTarget() != nil {
+			call.Target = o.optimize(c.GetTarget())
+		}
+		for _, arg := range c.GetArgs() {
+			call.Args = append(call.Args, o.optimize(arg))
+		}
+		return o.optimizeCall(&exprpb.Expr{Id: expr.GetId(), ExprKind: &exprpb.Expr_CallExpr{CallExpr: call}})
+	case *exprpb.Expr_SelectExpr:
+		s := kind.SelectExpr
+		return &exprpb.Expr{Id: expr.GetId(), ExprKind: &exprpb.Expr_SelectExpr{SelectExpr: &exprpb.Expr_Select{
+			Operand:  o.optimize(s.GetOperand()),
+			Field:    s.GetField(),
+			TestOnly: s.GetTestOnly(),
+		}}}
+	case *exprpb.Expr_ListExpr:
+		list := &exprpb.Expr_CreateList{}
+		for _, elem := range kind.ListExpr.GetElements() {
+			list.Elements = append(list.Elements, o.optimize(elem))
+		}
+		return &exprpb.Expr{Id: expr.GetId(), ExprKind: &exprpb.Expr_ListExpr{ListExpr: list}}
+	case *exprpb.Expr_StructExpr:
+		st := &exprpb.Expr_CreateStruct{MessageName: kind.StructExpr.GetMessageName()}
+		for _, entry := range kind.StructExpr.GetEntries() {
+			optimized := &exprpb.Expr_CreateStruct_Entry{Id: entry.GetId(), Value: o.optimize(entry.GetValue())}
+			if entry.GetMapKey() != nil {
+				optimized.KeyKind = &exprpb.Expr_CreateStruct_Entry_MapKey{MapKey: o.optimize(entry.GetMapKey())}
+			} else {
+				optimized.KeyKind = &exprpb.Expr_CreateStruct_Entry_FieldKey{FieldKey: entry.GetFieldKey()}
+			}
+			st.Entries = append(st.Entries, optimized)
+		}
+		return &exprpb.Expr{Id: expr.GetId(), ExprKind: &exprpb.Expr_StructExpr{StructExpr: st}}
+	case *exprpb.Expr_ComprehensionExpr:
+		c := kind.ComprehensionExpr
+		return &exprpb.Expr{Id: expr.GetId(), ExprKind: &exprpb.Expr_ComprehensionExpr{ComprehensionExpr: &exprpb.Expr_Comprehension{
+			IterVar:       c.GetIterVar(),
+			IterRange:     o.optimize(c.GetIterRange()),
+			AccuVar:       c.GetAccuVar(),
+			AccuInit:      o.optimize(c.GetAccuInit()),
+			LoopCondition: o.optimize(c.GetLoopCondition()),
+			LoopStep:      o.optimize(c.GetLoopStep()),
+			Result:        o.optimize(c.GetResult()),
+		}}}
+	}
+	return expr
+}
+
+func (o *optimizer) optimizeCall(expr *exprpb.Expr) *exprpb.Expr {
+	if folded, ok := o.fold(expr); ok {
+		return folded
+	}
+	c := expr.GetCallExpr()
+	args := c.GetArgs()
+	switch c.GetFunction() {
+	case operators.LogicalAnd, operators.LogicalOr:
+		// the operand which decides the result, e.g. false for &&.
+		decisive := c.GetFunction() == operators.LogicalOr
+		for i, arg := range args {
+			if !isBoolLiteral(arg) {
+				continue
+			}
+			if arg.GetConstExpr().GetBoolValue() == decisive {
+				return arg
+			}
+			return args[1-i]
+		}
+	case operators.LogicalNot:
+		if args[0].GetCallExpr().GetFunction() == operators.LogicalNot {
+			return args[0].GetCallExpr().GetArgs()[0]
+		}
+	case operators.Conditional:
+		if isBoolLiteral(args[0]) {
+			if args[0].GetConstExpr().GetBoolValue() {
+				return args[1]
+			}
+			return args[2]
+		}
+	case operators.In:
+		list := args[1].GetListExpr()
+		if list == nil {
+			break
+		}
+		switch len(list.GetElements()) {
+		case 0:
+			return newBoolLiteral(expr.GetId(), false)
+		case 1:
+			o.referenceMap[expr.GetId()] = &exprpb.Reference{OverloadId: []string{overloads.Equals}}
+			return &exprpb.Expr{Id: expr.GetId(), ExprKind: &exprpb.Expr_CallExpr{CallExpr: &exprpb.Expr_Call{
+				Function: operators.Equals,
+				Args:     []*exprpb.Expr{args[0], list.GetElements()[0]},
+			}}}
+		}
+	}
+	return expr
+}
+
+var standardOverloads = func() map[string]bool {
+	ids := map[string]bool{}
+	for _, declaration := range checker.StandardDeclarations() {
+		for _, overload := range declaration.GetFunction().GetOverloads() {
+			ids[overload.GetOverloadId()] = true
+		}
+	}
+	return ids
+}()
+
+var foldingEnv, _ = cel.NewEnv()
+
+// fold evaluates the call of a standard function with constant arguments, and returns the literal
+// of the result if it can be a literal.
+func (o *optimizer) fold(expr *exprpb.Expr) (*exprpb.Expr, bool) {
+	constant := true
+	walkExpr(expr, func(e *exprpb.Expr) {
+		switch e.ExprKind.(type) {
+		case *exprpb.Expr_ConstExpr, *exprpb.Expr_ListExpr:
+		case *exprpb.Expr_CallExpr:
+			overloadIDs := o.referenceMap[e.GetId()].GetOverloadId()
+			if len(overloadIDs) == 0 {
+				constant = false
+			}
+			for _, id := range overloadIDs {
+				if !standardOverloads[id] {
+					constant = false
+				}
+			}
+		default:
+			constant = false
+		}
+	})
+	if !constant {
+		return nil, false
+	}
+	program, err := foldingEnv.Program(cel.CheckedExprToAst(&exprpb.CheckedExpr{
+		ReferenceMap: o.referenceMap,
+		TypeMap:      o.checkedExpr.GetTypeMap(),
+		SourceInfo:   o.checkedExpr.GetSourceInfo(),
+		Expr:         expr,
+	}))
+	if err != nil {
+		return nil, false
+	}
+	value, _, err := program.Eval(map[string]interface{}{})
+	if err != nil {
+		return nil, false
+	}
+	return newLiteral(expr.GetId(), value)
+}
+
+func newBoolLiteral(id int64, value bool) *exprpb.Expr {
+	return &exprpb.Expr{Id: id, ExprKind: &exprpb.Expr_ConstExpr{ConstExpr: &exprpb.Constant{
+		ConstantKind: &exprpb.Constant_BoolValue{BoolValue: value},
+	}}}
+}
+
+// newLiteral returns the literal of the primitive value.
+func newLiteral(id int64, value ref.Val) (*exprpb.Expr, bool) {
+	var constant *exprpb.Constant
+	switch v := value.(type) {
+	case types.Bool:
+		constant = &exprpb.Constant{ConstantKind: &exprpb.Constant_BoolValue{BoolValue: bool(v)}}
+	case types.Int:
+		constant = &exprpb.Constant{ConstantKind: &exprpb.Constant_Int64Value{Int64Value: int64(v)}}
+	case types.Uint:
+		constant = &exprpb.Constant{ConstantKind: &exprpb.Constant_Uint64Value{Uint64Value: uint64(v)}}
+	case types.Double:
+		constant = &exprpb.Constant{ConstantKind: &exprpb.Constant_DoubleValue{DoubleValue: float64(v)}}
+	case types.String:
+		constant = &exprpb.Constant{ConstantKind: &exprpb.Constant_StringValue{StringValue: string(v)}}
+	case types.Bytes:
+		constant = &exprpb.Constant{ConstantKind: &exprpb.Constant_BytesValue{BytesValue: []byte(v)}}
+	default:
+		return nil, false
+	}
+	return &exprpb.Expr{Id: id, ExprKind: &exprpb.Expr_ConstExpr{ConstExpr: constant}}, true
+}
@@ -0,0 +1,158 @@
+package cel2sql_test
+
+import (
+	"testing"
+
+	"cloud.google.com/go/bigquery"
+	"github.com/google/cel-go/cel"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/cockscomb/cel2sql"
+	"github.com/cockscomb/cel2sql/test"
+)
+
+func TestOptimize(t *testing.T) {
+	env, err := cel2sql.NewEnv(
+		map[string]bigquery.Schema{
+			"wikipedia": test.NewWikipediaTableMetadata().Schema,
+		},
+		cel2sql.TableVariable("page", "wikipedia"),
+	)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name   string
+		source string
+		want   string
+	}{
+		{
+			name:   "arithmetic",
+			source: `1 + 2 > page.id`,
+			want:   "3 > `page`.`id`",
+		},
+		{
+			name:   "strings",
+			source: `page.title == "a" + "b" && size("abc") < page.id`,
+			want:   "`page`.`title` = \"ab\" AND 3 < `page`.`id`",
+		},
+		{
+			name:   "and",
+			source: `true && page.id > 1 && (page.is_bot || false)`,
+			want:   "`page`.`id` > 1 AND `page`.`is_bot`",
+		},
+		{
+			name:   "shortCircuit",
+			source: `page.id > 1 && (1 > 2) || page.is_bot`,
+			want:   "`page`.`is_bot`",
+		},
+		{
+			name:   "or",
+			source: `page.id > 1 || 2 > 1`,
+			want:   "TRUE",
+		},
+		{
+			name:   "doubleNegation",
+			source: `!(!(page.is_bot))`,
+			want:   "`page`.`is_bot`",
+		},
+		{
+			name:   "conditional",
+			source: `(1 < 2 ? page.id : page.revision_id) > 0`,
+			want:   "`page`.`id` > 0",
+		},
+		{
+			name:   "inSingle",
+			source: `page.language in ["en"]`,
+			want:   "`page`.`language` = \"en\"",
+		},
+		{
+			name:   "inEmpty",
+			source: `page.id > 0 && page.language in []`,
+			want:   "FALSE",
+		},
+		{
+			name:   "inFolded",
+			source: `page.wp_namespace in [1 + 1]`,
+			want:   "`page`.`wp_namespace` = 2",
+		},
+		{
+			name:   "error",
+			source: `page.id > 1 / 0`,
+			want:   "`page`.`id` > 1 / 0",
+		},
+		{
+			name:   "unchanged",
+			source: `page.language in ["en", "ja"] && page.title.startsWith("a")`,
+			want:   "`page`.`language` IN UNNEST([\"en\", \"ja\"]) AND STARTS_WITH(`page`.`title`, \"a\")",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ast, issues := env.Compile(tt.source)
+			require.Empty(t, issues)
+			optimized, err := cel2sql.Optimize(ast)
+			require.NoError(t, err)
+			got, err := cel2sql.Convert(optimized)
+			if assert.NoError(t, err) {
+				assert.Equal(t, tt.want, got)
+			}
+		})
+	}
+}
+
+func TestOptimize_nested(t *testing.T) {
+	env, err := cel2sql.NewEnv(
+		map[string]bigquery.Schema{
+			"wikipedia": test.NewWikipediaTableMetadata().Schema,
+		},
+		cel2sql.TableVariable("page", "wikipedia"),
+	)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name   string
+		source string
+		want   string
+	}{
+		{
+			name:   "map",
+			source: `{"a": 1 + 2}["a"] > page.id`,
+			want:   `{"a": 3}["a"] > page.id`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ast, issues := env.Compile(tt.source)
+			require.Empty(t, issues)
+			optimized, err := cel2sql.Optimize(ast)
+			require.NoError(t, err)
+			got, err := cel.AstToString(optimized)
+			if assert.NoError(t, err) {
+				assert.Equal(t, tt.want, got)
+			}
+		})
+	}
+}
+
+func TestOptimize_comprehension(t *testing.T) {
+	env, err := cel2sql.NewEnv(
+		map[string]bigquery.Schema{
+			"wikipedia": test.NewWikipediaTableMetadata().Schema,
+		},
+		cel2sql.TableVariable("page", "wikipedia"),
+	)
+	require.NoError(t, err)
+	ast, issues := env.Compile(`[page.id, 1 + 1].exists(x, x > 2 * 5)`)
+	require.Empty(t, issues)
+
+	optimized, err := cel2sql.Optimize(ast)
+	require.NoError(t, err)
+	checkedExpr, err := cel.AstToCheckedExpr(optimized)
+	require.NoError(t, err)
+	comprehension := checkedExpr.GetExpr().GetComprehensionExpr()
+	require.NotNil(t, comprehension)
+	assert.Equal(t, int64(2), comprehension.GetIterRange().GetListExpr().GetElements()[1].GetConstExpr().GetInt64Value())
+	predicate := comprehension.GetLoopStep().GetCallExpr().GetArgs()[1]
+	assert.Equal(t, int64(10), predicate.GetCallExpr().GetArgs()[1].GetConstExpr().GetInt64Value())
+}
@@ -0,0 +1,105 @@
+package cel2sql
+
+import (
+	"sort"
+
+	"github.com/google/cel-go/common/types/ref"
+
+	"github.com/cockscomb/cel2sql/bq"
+)
+
+// ConvertOption configures the conversion performed by Convert.
+type ConvertOption func(*converter)
+
+type rowColumns struct {
+	provider  ref.TypeProvider
+	table     string
+	qualifier string
+}
+
+func (r *rowColumns) isColumn(name string) bool {
+	if r == nil {
+		return false
+	}
+	_, found := r.provider.FindFieldType(r.table, name)
+	return found
+}
+
+// RowColumns treats top-level identifiers naming fields of the table as its columns, as declared
+// by the RowTable environment option. Columns are qualified with the qualifier, e.g. `t`.`status`,
+// or left unqualified when the qualifier is empty.
+func RowColumns(provider ref.TypeProvider, table string, qualifier string) ConvertOption {
+	return func(con *converter) {
+		con.rowColumns = &rowColumns{
+			provider:  provider,
+			table:     table,
+			qualifier: qualifier,
+		}
+	}
+}
+
+// ColumnNames renders fields by the physical column names of the mapper, e.g. `createdAt` as
+// `created_at`. Use the same mapper for the type provider with bq.ColumnNames.
+func ColumnNames(names bq.NameMapper) ConvertOption {
+	return func(con *converter) {
+		con.names = names
+	}
+}
+
+// TableSource renders the table variable as the SQL source, which is an alias such as `t0`, a
+// qualified table name such as `project.dataset.table`, or empty to leave the columns of the
+// variable unqualified. The columns of a qualified table name are qualified by its last component,
+// e.g. `table`.`name`, which is the implicit alias of the table in BigQuery.
+func TableSource(variable string, source string) ConvertOption {
+	return func(con *converter) {
+		if con.tableSources == nil {
+			con.tableSources = map[string]string{}
+		}
+		con.tableSources[variable] = source
+	}
+}
+
+// FieldTypes looks up the types of the fields in the provider, so that has() of a repeated field
+// is rendered as the test of a non-empty array instead of IS NOT NULL. Without it, the fields
+// tested by has() are assumed to be scalar, unless the provider of RowColumns knows them.
+func FieldTypes(provider ref.TypeProvider) ConvertOption {
+	return func(con *converter) {
+		con.fieldTypes = provider
+	}
+}
+
+// QueryParameters renders the identifiers named by the parameters as the named query parameters,
+// e.g. `cursor` as @cursor. Only the names are used; the values are bound by the caller.
+//
+// PostgreSQL has no named parameters, so they are rendered as the positional parameters $1, $2, ...
+// numbered in the order of their sorted names, which is the order of PositionalParameters.
+func QueryParameters(parameters map[string]interface{}) ConvertOption {
+	return func(con *converter) {
+		if con.parameters == nil {
+			con.parameters = map[string]bool{}
+		}
+		for name := range parameters {
+			con.parameters[name] = true
+		}
+	}
+}
+
+// PositionalParameters returns the values of the parameters in the order of their sorted names,
+// which are bound to the positional parameters of PostgreSQL rendered by QueryParameters.
+func PositionalParameters(parameters map[string]interface{}) []interface{} {
+	names := parameterNames(parameters)
+	values := make([]interface{}, len(names))
+	for i, name := range names {
+		values[i] = parameters[name]
+	}
+	return values
+}
+
+func parameterNames(parameters map[string]interface{}) []string {
+	names := make([]string, 0, len(parameters))
+	for name := range parameters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
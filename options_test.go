@@ -0,0 +1,314 @@
+package cel2sql_test
+
+import (
+	"strings"
+	"testing"
+	"unicode"
+
+	"cloud.google.com/go/bigquery"
+	"github.com/google/cel-go/checker/decls"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/cockscomb/cel2sql"
+	"github.com/cockscomb/cel2sql/bq"
+	"github.com/cockscomb/cel2sql/test"
+)
+
+func TestRowColumns(t *testing.T) {
+	env, err := cel2sql.NewEnv(
+		map[string]bigquery.Schema{
+			"wikipedia": test.NewWikipediaTableMetadata().Schema,
+		},
+		cel2sql.RowTable("wikipedia"),
+		cel2sql.Declarations(decls.NewVar("min_length", decls.Int)),
+	)
+	require.NoError(t, err)
+
+	type args struct {
+		source    string
+		qualifier string
+	}
+	tests := []struct {
+		name string
+		args args
+		want string
+	}{
+		{
+			name: "unqualified",
+			args: args{
+				source:    `title == "a" && num_characters > min_length`,
+				qualifier: "",
+			},
+			want: "`title` = \"a\" AND `num_characters` > `min_length`",
+		},
+		{
+			name: "qualified",
+			args: args{
+				source:    `title == "a" && num_characters > min_length`,
+				qualifier: "t",
+			},
+			want: "`t`.`title` = \"a\" AND `t`.`num_characters` > `min_length`",
+		},
+		{
+			name: "qualified_function",
+			args: args{
+				source:    `title.startsWith("a") || size(comment) == 0`,
+				qualifier: "t",
+			},
+			want: "STARTS_WITH(`t`.`title`, \"a\") OR LENGTH(`t`.`comment`) = 0",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ast, issues := env.Compile(tt.args.source)
+			require.Empty(t, issues)
+
+			got, err := cel2sql.Convert(ast, cel2sql.RowColumns(env.TypeProvider(), "wikipedia", tt.args.qualifier))
+			if assert.NoError(t, err) {
+				assert.Equal(t, tt.want, got)
+			}
+		})
+	}
+}
+
+func camelToSnake(_ string, name string) string {
+	var b strings.Builder
+	for _, r := range name {
+		if unicode.IsUpper(r) {
+			b.WriteRune('_')
+			r = unicode.ToLower(r)
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+func snakeToCamel(_ string, name string) string {
+	var b strings.Builder
+	upper := false
+	for _, r := range name {
+		if r == '_' {
+			upper = true
+			continue
+		}
+		if upper {
+			r = unicode.ToUpper(r)
+			upper = false
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+func TestColumnNames(t *testing.T) {
+	schemas := map[string]bigquery.Schema{
+		"trigrams":  test.NewTrigramsTableMetadata().Schema,
+		"wikipedia": test.NewWikipediaTableMetadata().Schema,
+	}
+	type args struct {
+		names  bq.NameMapper
+		opts   []cel2sql.EnvOption
+		source string
+	}
+	tests := []struct {
+		name string
+		args args
+		want string
+	}{
+		{
+			name: "nameMap",
+			args: args{
+				names: bq.NameMap{
+					"trigrams":       {"cells": "cell"},
+					"trigrams.cells": {"pageCount": "page_count"},
+				},
+				opts:   []cel2sql.EnvOption{cel2sql.TableVariable("trigram", "trigrams")},
+				source: `trigram.cells[0].pageCount > 1 && has(trigram.ngram)`,
+			},
+			want: "`trigram`.`cell`[OFFSET(0)].`page_count` > 1 AND `trigram`.`ngram` IS NOT NULL",
+		},
+		{
+			name: "nameMapperFuncs",
+			args: args{
+				names:  bq.NameMapperFuncs{ToColumnName: camelToSnake, ToFieldName: snakeToCamel},
+				opts:   []cel2sql.EnvOption{cel2sql.TableVariable("page", "wikipedia")},
+				source: `page.isRedirect == false && page.contributorUsername.startsWith("a")`,
+			},
+			want: "`page`.`is_redirect` IS FALSE AND STARTS_WITH(`page`.`contributor_username`, \"a\")",
+		},
+		{
+			name: "rowTable",
+			args: args{
+				names:  bq.NameMapperFuncs{ToColumnName: camelToSnake, ToFieldName: snakeToCamel},
+				opts:   []cel2sql.EnvOption{cel2sql.RowTable("wikipedia")},
+				source: `numCharacters > 100 && wpNamespace == 0`,
+			},
+			want: "`num_characters` > 100 AND `wp_namespace` = 0",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := append([]cel2sql.EnvOption{cel2sql.ProviderOptions(bq.ColumnNames(tt.args.names))}, tt.args.opts...)
+			env, err := cel2sql.NewEnv(schemas, opts...)
+			require.NoError(t, err)
+
+			ast, issues := env.Compile(tt.args.source)
+			require.Empty(t, issues)
+
+			got, err := cel2sql.Convert(ast,
+				cel2sql.RowColumns(env.TypeProvider(), "wikipedia", ""),
+				cel2sql.ColumnNames(tt.args.names),
+			)
+			if assert.NoError(t, err) {
+				assert.Equal(t, tt.want, got)
+			}
+		})
+	}
+}
+
+func TestTableSource(t *testing.T) {
+	env, err := cel2sql.NewEnv(
+		map[string]bigquery.Schema{
+			"trigrams":  test.NewTrigramsTableMetadata().Schema,
+			"wikipedia": test.NewWikipediaTableMetadata().Schema,
+		},
+		cel2sql.TableVariable("trigram", "trigrams"),
+		cel2sql.TableVariable("page", "wikipedia"),
+	)
+	require.NoError(t, err)
+
+	type args struct {
+		source string
+		opts   []cel2sql.ConvertOption
+	}
+	tests := []struct {
+		name    string
+		args    args
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "alias",
+			args: args{
+				source: `trigram.first == page.title`,
+				opts: []cel2sql.ConvertOption{
+					cel2sql.TableSource("trigram", "t0"),
+					cel2sql.TableSource("page", "t1"),
+				},
+			},
+			want: "`t0`.`first` = `t1`.`title`",
+		},
+		{
+			name: "qualified",
+			args: args{
+				source: `page.title.startsWith("a")`,
+				opts: []cel2sql.ConvertOption{
+					cel2sql.TableSource("page", "bigquery-public-data.samples.wikipedia"),
+				},
+			},
+			want: "STARTS_WITH(`wikipedia`.`title`, \"a\")",
+		},
+		{
+			name: "unqualified",
+			args: args{
+				source: `trigram.cell[0].page_count > 1 && has(trigram.ngram)`,
+				opts: []cel2sql.ConvertOption{
+					cel2sql.TableSource("trigram", ""),
+				},
+			},
+			want: "`cell`[OFFSET(0)].`page_count` > 1 AND `ngram` IS NOT NULL",
+		},
+		{
+			name: "escaped",
+			args: args{
+				source: `page.title == "a"`,
+				opts: []cel2sql.ConvertOption{
+					cel2sql.TableSource("page", "p`\\"),
+				},
+			},
+			want: "`p\\`\\\\`.`title` = \"a\"",
+		},
+		{
+			name: "unqualified_itself",
+			args: args{
+				source: `trigram == trigram`,
+				opts: []cel2sql.ConvertOption{
+					cel2sql.TableSource("trigram", ""),
+				},
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ast, issues := env.Compile(tt.args.source)
+			require.Empty(t, issues)
+
+			got, err := cel2sql.Convert(ast, tt.args.opts...)
+			if !tt.wantErr && assert.NoError(t, err) {
+				assert.Equal(t, tt.want, got)
+			} else {
+				assert.Error(t, err)
+			}
+		})
+	}
+}
+
+func TestFieldTypes(t *testing.T) {
+	env, err := cel2sql.NewEnv(
+		map[string]bigquery.Schema{
+			"trigrams": test.NewTrigramsTableMetadata().Schema,
+		},
+		cel2sql.TableVariable("trigram", "trigrams"),
+	)
+	require.NoError(t, err)
+	fieldTypes := cel2sql.FieldTypes(env.TypeProvider())
+
+	tests := []struct {
+		name   string
+		source string
+		opts   []cel2sql.ConvertOption
+		want   string
+	}{
+		{
+			name:   "scalar",
+			source: `has(trigram.ngram) && !has(trigram.first)`,
+			opts:   []cel2sql.ConvertOption{fieldTypes},
+			want:   "`trigram`.`ngram` IS NOT NULL AND `trigram`.`first` IS NULL",
+		},
+		{
+			name:   "repeated",
+			source: `has(trigram.cell)`,
+			opts:   []cel2sql.ConvertOption{fieldTypes},
+			want:   "ARRAY_LENGTH(`trigram`.`cell`) > 0",
+		},
+		{
+			name:   "negatedRepeated",
+			source: `!has(trigram.cell) || has(trigram.cell[0].sample)`,
+			opts:   []cel2sql.ConvertOption{fieldTypes},
+			want:   "ARRAY_LENGTH(`trigram`.`cell`) = 0 OR ARRAY_LENGTH(`trigram`.`cell`[OFFSET(0)].`sample`) > 0",
+		},
+		{
+			name:   "postgreSQL",
+			source: `has(trigram.cell) && !has(trigram.cell)`,
+			opts:   []cel2sql.ConvertOption{fieldTypes, cel2sql.SQLDialect(cel2sql.PostgreSQL)},
+			want:   `COALESCE(CARDINALITY("trigram"."cell"), 0) > 0 AND COALESCE(CARDINALITY("trigram"."cell"), 0) = 0`,
+		},
+		{
+			name:   "unknownFieldTypes",
+			source: `has(trigram.cell)`,
+			want:   "`trigram`.`cell` IS NOT NULL",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ast, issues := env.Compile(tt.source)
+			require.Empty(t, issues)
+
+			got, err := cel2sql.Convert(ast, tt.opts...)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
@@ -0,0 +1,171 @@
+package cel2sql
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/google/cel-go/cel"
+	"github.com/google/cel-go/common/types"
+	"github.com/google/cel-go/common/types/ref"
+	"github.com/google/cel-go/interpreter"
+	"github.com/google/cel-go/interpreter/functions"
+	exprpb "google.golang.org/genproto/googleapis/api/expr/v1alpha1"
+)
+
+// PartialEvalOption configures PartialEval.
+type PartialEvalOption func(*partialEvaluator)
+
+type partialEvaluator struct {
+	parameters map[string]bool
+}
+
+// BindParameters binds the known variables as query parameters instead of inlining them as
+// literals. The sub-expressions referencing them are not folded.
+func BindParameters(variables ...string) PartialEvalOption {
+	return func(p *partialEvaluator) {
+		if p.parameters == nil {
+			p.parameters = map[string]bool{}
+		}
+		for _, variable := range variables {
+			p.parameters[variable] = true
+		}
+	}
+}
+
+// Residual is the result of PartialEval.
+type Residual struct {
+	// Ast is the residual expression over the unknown variables, such as table variables.
+	Ast *cel.Ast
+	// Parameters are the values of the known variables bound as query parameters, which are
+	// converted with QueryParameters.
+	Parameters map[string]interface{}
+}
+
+// PartialEval evaluates the checked AST with the values of the known variables, e.g. `request` and
+// `now`, and returns the residual AST in which the variables are inlined as literals and the
+// constant sub-expressions are folded. All the other variables referenced by the AST are unknown.
+//
+// Known values which cannot be literals, such as timestamps, are bound as query parameters when
+// they are referenced as a whole, or otherwise are reported as errors.
+func PartialEval(env *cel.Env, ast *cel.Ast, known map[string]interface{}, opts ...PartialEvalOption) (*Residual, error) {
+	p := &partialEvaluator{}
+	for _, opt := range opts {
+		opt(p)
+	}
+	checkedExpr, err := cel.AstToCheckedExpr(ast)
+	if err != nil {
+		return nil, err
+	}
+
+	vars := map[string]interface{}{}
+	var unknowns []*interpreter.AttributePattern
+	for variable := range referencedVariables(checkedExpr) {
+		if value, found := known[variable]; found && !p.parameters[variable] {
+			vars[variable] = value
+		} else {
+			unknowns = append(unknowns, cel.AttributePattern(variable))
+		}
+	}
+	activation, err := cel.PartialVars(vars, unknowns...)
+	if err != nil {
+		return nil, err
+	}
+	program, err := env.Program(ast,
+		cel.EvalOptions(cel.OptTrackState, cel.OptPartialEval),
+		cel.Functions(sqlOnlyFunctions(checkedExpr)...),
+	)
+	if err != nil {
+		return nil, err
+	}
+	_, details, err := program.Eval(activation)
+	if details == nil {
+		return nil, err
+	}
+	// The residual AST would lose the sub-expressions evaluated to errors.
+	if err := evalError(checkedExpr, details.State()); err != nil {
+		return nil, err
+	}
+	residual, err := env.ResidualAst(ast, details)
+	if err != nil {
+		return nil, err
+	}
+
+	residualExpr, err := cel.AstToCheckedExpr(residual)
+	if err != nil {
+		return nil, err
+	}
+	parameters := map[string]interface{}{}
+	var failed []string
+	for variable := range referencedVariables(residualExpr) {
+		value, found := known[variable]
+		if !found {
+			continue
+		}
+		if isReferencedAsWhole(residualExpr, variable) {
+			parameters[variable] = value
+		} else {
+			failed = append(failed, variable)
+		}
+	}
+	if len(failed) > 0 {
+		sort.Strings(failed)
+		return nil, fmt.Errorf("known variables cannot be inlined: %s", strings.Join(failed, ", "))
+	}
+	return &Residual{Ast: residual, Parameters: parameters}, nil
+}
+
+var standardFunctions = func() map[string]bool {
+	names := map[string]bool{}
+	for _, overload := range functions.StandardOverloads() {
+		names[overload.Operator] = true
+	}
+	return names
+}()
+
+// sqlOnlyFunctions returns the overloads of the functions without implementations in Go, such as
+// current_date(), which evaluate to unknowns to be left in the residual AST.
+func sqlOnlyFunctions(checkedExpr *exprpb.CheckedExpr) []*functions.Overload {
+	var overloads []*functions.Overload
+	seen := map[string]bool{}
+	walkExpr(checkedExpr.GetExpr(), func(expr *exprpb.Expr) {
+		name := expr.GetCallExpr().GetFunction()
+		if name == "" || standardFunctions[name] || seen[name] {
+			return
+		}
+		seen[name] = true
+		unknown := types.Unknown{expr.GetId()}
+		overloads = append(overloads, &functions.Overload{
+			Operator: name,
+			Unary:    func(ref.Val) ref.Val { return unknown },
+			Binary:   func(ref.Val, ref.Val) ref.Val { return unknown },
+			Function: func(...ref.Val) ref.Val { return unknown },
+		})
+	})
+	return overloads
+}
+
+// evalError returns the first error which any sub-expression evaluated to.
+func evalError(checkedExpr *exprpb.CheckedExpr, state interpreter.EvalState) error {
+	var err error
+	walkExpr(checkedExpr.GetExpr(), func(expr *exprpb.Expr) {
+		if value, found := state.Value(expr.GetId()); found && err == nil && types.IsError(value) {
+			err = value.(*types.Err)
+		}
+	})
+	return err
+}
+
+// isReferencedAsWhole reports whether the variable is referenced only by itself, not by selecting
+// its fields.
+func isReferencedAsWhole(checkedExpr *exprpb.CheckedExpr, variable string) bool {
+	whole := true
+	walkExpr(checkedExpr.GetExpr(), func(expr *exprpb.Expr) {
+		if operand := expr.GetSelectExpr().GetOperand(); operand != nil {
+			if checkedExpr.GetReferenceMap()[operand.GetId()].GetName() == variable {
+				whole = false
+			}
+		}
+	})
+	return whole
+}
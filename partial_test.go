@@ -0,0 +1,122 @@
+package cel2sql_test
+
+import (
+	"testing"
+	"time"
+
+	"cloud.google.com/go/bigquery"
+	"github.com/google/cel-go/checker/decls"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/cockscomb/cel2sql"
+	"github.com/cockscomb/cel2sql/test"
+)
+
+func TestPartialEval(t *testing.T) {
+	env, err := cel2sql.NewEnv(
+		map[string]bigquery.Schema{
+			"wikipedia": test.NewWikipediaTableMetadata().Schema,
+		},
+		cel2sql.TableVariable("page", "wikipedia"),
+		cel2sql.Declarations(
+			decls.NewVar("request", decls.NewMapType(decls.String, decls.Dyn)),
+			decls.NewVar("now", decls.Timestamp),
+			decls.NewVar("limit", decls.Int),
+		),
+	)
+	require.NoError(t, err)
+	now := time.Date(2021, 1, 2, 3, 4, 5, 0, time.UTC)
+	known := map[string]interface{}{
+		"request": map[string]interface{}{
+			"user_id": 42,
+			"admin":   false,
+			"languages": []string{
+				"en",
+				"ja",
+			},
+		},
+		"now":   now,
+		"limit": 100,
+	}
+
+	tests := []struct {
+		name           string
+		source         string
+		opts           []cel2sql.PartialEvalOption
+		want           string
+		wantParameters map[string]interface{}
+		wantErr        bool
+	}{
+		{
+			name:           "literal",
+			source:         `page.contributor_id == request.user_id`,
+			want:           "`page`.`contributor_id` = 42",
+			wantParameters: map[string]interface{}{},
+		},
+		{
+			name:           "folding",
+			source:         `request.admin || page.contributor_id == request.user_id && page.id < limit * 2`,
+			want:           "`page`.`contributor_id` = 42 AND `page`.`id` < 200",
+			wantParameters: map[string]interface{}{},
+		},
+		{
+			name:           "constant",
+			source:         `!request.admin`,
+			want:           "TRUE",
+			wantParameters: map[string]interface{}{},
+		},
+		{
+			name:           "list",
+			source:         `page.language in request.languages`,
+			want:           "`page`.`language` IN UNNEST([\"en\", \"ja\"])",
+			wantParameters: map[string]interface{}{},
+		},
+		{
+			name:           "timestamp",
+			source:         `timestamp(page.timestamp) < now`,
+			want:           "TIMESTAMP(`page`.`timestamp`) < @now",
+			wantParameters: map[string]interface{}{"now": now},
+		},
+		{
+			name:           "sqlFunction",
+			source:         `current_date() > date("2020-01-01") && page.id < limit`,
+			want:           "CURRENT_DATE() > DATE(\"2020-01-01\") AND `page`.`id` < 100",
+			wantParameters: map[string]interface{}{},
+		},
+		{
+			name:           "bindParameters",
+			source:         `page.id < limit`,
+			opts:           []cel2sql.PartialEvalOption{cel2sql.BindParameters("limit")},
+			want:           "`page`.`id` < @limit",
+			wantParameters: map[string]interface{}{"limit": 100},
+		},
+		{
+			name:    "divideByZero",
+			source:  `page.title != "" && page.id < 1 / (limit - 100)`,
+			wantErr: true,
+		},
+		{
+			name:    "missingKey",
+			source:  `page.contributor_id == request.missing`,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ast, issues := env.Compile(tt.source)
+			require.Empty(t, issues)
+			got, err := cel2sql.PartialEval(env, ast, known, tt.opts...)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			sql, err := cel2sql.Convert(got.Ast, cel2sql.QueryParameters(got.Parameters))
+			if assert.NoError(t, err) {
+				assert.Equal(t, tt.want, sql)
+				assert.Equal(t, tt.wantParameters, got.Parameters)
+			}
+		})
+	}
+}
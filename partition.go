@@ -0,0 +1,215 @@
+package cel2sql
+
+import (
+	"fmt"
+
+	"github.com/google/cel-go/cel"
+	"github.com/google/cel-go/common/operators"
+	"github.com/google/cel-go/parser"
+	exprpb "google.golang.org/genproto/googleapis/api/expr/v1alpha1"
+
+	"github.com/cockscomb/cel2sql/bq"
+	"github.com/cockscomb/cel2sql/composite"
+)
+
+// PartitionFilterError reports that a filter does not prune the partitions of the table of the
+// variable, which BigQuery rejects when the table requires a partition filter.
+type PartitionFilterError struct {
+	Variable string
+	Field    string
+}
+
+func (e *PartitionFilterError) Error() string {
+	return fmt.Sprintf("filter must constrain the partitioning field \"%s\" of \"%s\"", e.Field, e.Variable)
+}
+
+// PartitionOption configures EnforcePartitionFilter.
+type PartitionOption func(*partitionConfig)
+
+type partitionConfig struct {
+	rejectUnpruned bool
+	defaultFilter  string
+}
+
+// RejectUnprunedPartitions rejects the filters which do not prune the partitions even if the table
+// does not require a partition filter.
+func RejectUnprunedPartitions() PartitionOption {
+	return func(c *partitionConfig) {
+		c.rejectUnpruned = true
+	}
+}
+
+// DefaultPartitionFilter conjoins the filters which do not prune the partitions with the CEL
+// source, e.g. `page.created_at >= current_timestamp() - duration("720h")`. It must prune the
+// partitions itself.
+func DefaultPartitionFilter(source string) PartitionOption {
+	return func(c *partitionConfig) {
+		c.defaultFilter = source
+	}
+}
+
+// PrunesPartitions reports whether the filter prunes the partitions of the table of the variable
+// in BigQuery, i.e. one of its conjuncts compares only the partitioning field with constant
+// expressions, e.g. `page.created_at >= current_timestamp() - duration("24h")`. It returns an
+// error if the table is not partitioned.
+func PrunesPartitions(env *cel.Env, ast *cel.Ast, variable string) (bool, error) {
+	field, _, err := partitionField(env, variable)
+	if err != nil {
+		return false, err
+	}
+	if field == "" {
+		return false, fmt.Errorf("table of variable \"%s\" is not partitioned", variable)
+	}
+	return prunesPartitions(ast, partitionFields(variable, field))
+}
+
+// EnforcePartitionFilter returns the filter which prunes the partitions of the table of the
+// variable. A filter which does not prune them is conjoined with DefaultPartitionFilter, or
+// rejected by *PartitionFilterError if the table requires a partition filter or by
+// RejectUnprunedPartitions. Filters of tables which are not partitioned are returned as is.
+func EnforcePartitionFilter(env *cel.Env, ast *cel.Ast, variable string, opts ...PartitionOption) (*cel.Ast, error) {
+	config := &partitionConfig{}
+	for _, opt := range opts {
+		opt(config)
+	}
+	field, required, err := partitionField(env, variable)
+	if err != nil || field == "" {
+		return ast, err
+	}
+	fields := partitionFields(variable, field)
+	pruned, err := prunesPartitions(ast, fields)
+	if err != nil || pruned {
+		return ast, err
+	}
+	if config.defaultFilter != "" {
+		defaultFilter, issues := env.Compile(config.defaultFilter)
+		if issues != nil && issues.Err() != nil {
+			return nil, issues.Err()
+		}
+		if pruned, err := prunesPartitions(defaultFilter, fields); err != nil {
+			return nil, err
+		} else if !pruned {
+			return nil, fmt.Errorf("default partition filter does not prune the partitions: %s", config.defaultFilter)
+		}
+		source, err := cel.AstToString(ast)
+		if err != nil {
+			return nil, err
+		}
+		filter, issues := env.Compile(fmt.Sprintf("(%s) && (%s)", source, config.defaultFilter))
+		if issues != nil && issues.Err() != nil {
+			return nil, issues.Err()
+		}
+		return filter, nil
+	}
+	if required || config.rejectUnpruned {
+		return nil, &PartitionFilterError{Variable: variable, Field: field}
+	}
+	return ast, nil
+}
+
+// partitionField returns the partitioning field of the table of the variable, which is empty if
+// the table is not partitioned, and whether the table requires a partition filter.
+func partitionField(env *cel.Env, variable string) (string, bool, error) {
+	checkedExpr, err := compileField(env, "", variable)
+	if err != nil {
+		return "", false, err
+	}
+	table := checkedExpr.GetTypeMap()[checkedExpr.GetExpr().GetId()].GetMessageType()
+	if table == "" {
+		return "", false, fmt.Errorf("variable \"%s\" is not a table", variable)
+	}
+	partitioner, ok := env.TypeProvider().(composite.Partitioner)
+	if !ok {
+		return "", false, nil
+	}
+	field, found := partitioner.PartitionField(table)
+	if !found {
+		return "", false, nil
+	}
+	return field, partitioner.RequiresPartitionFilter(table), nil
+}
+
+// partitionFields returns the fields of the variable which prune the partitions, including
+// _PARTITIONDATE of ingestion-time partitioned tables.
+func partitionFields(variable string, field string) map[string]bool {
+	fields := map[string]bool{variable + "." + field: true}
+	if field == bq.PartitionTime {
+		fields[variable+"."+bq.PartitionDate] = true
+	}
+	return fields
+}
+
+func prunesPartitions(ast *cel.Ast, fields map[string]bool) (bool, error) {
+	if ast.ResultType().GetPrimitive() != exprpb.Type_BOOL {
+		return false, fmt.Errorf("expression must be bool but %s", cel.FormatType(ast.ResultType()))
+	}
+	checkedExpr, err := cel.AstToCheckedExpr(ast)
+	if err != nil {
+		return false, err
+	}
+	n := newNormalizer(checkedExpr, ConjunctiveNormalForm)
+	clauses, err := n.clauses(checkedExpr.GetExpr(), false)
+	if _, exceeded := err.(*ClauseLimitError); exceeded {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	for _, clause := range clauses {
+		if len(clause) == 0 {
+			continue
+		}
+		pruning := true
+		for _, literal := range clause {
+			if !isPartitionRange(checkedExpr, literal, fields) {
+				pruning = false
+			}
+		}
+		if pruning {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// isPartitionRange reports whether the literal compares the partitioning field with constant
+// expressions, which selects a range of the partitions.
+func isPartitionRange(checkedExpr *exprpb.CheckedExpr, expr *exprpb.Expr, fields map[string]bool) bool {
+	negated := false
+	if c := expr.GetCallExpr(); c.GetFunction() == operators.LogicalNot {
+		expr, negated = c.GetArgs()[0], true
+	}
+	c := expr.GetCallExpr()
+	switch c.GetFunction() {
+	case operators.Less, operators.LessEquals, operators.Greater, operators.GreaterEquals:
+	case operators.Equals, operators.In:
+		if negated {
+			return false
+		}
+	default:
+		return false
+	}
+	args := c.GetArgs()
+	for i, arg := range args {
+		if !isField(arg) {
+			continue
+		}
+		source, err := parser.Unparse(arg, checkedExpr.GetSourceInfo())
+		if err == nil && fields[source] && isConstantExpr(args[1-i]) {
+			return true
+		}
+	}
+	return false
+}
+
+// isConstantExpr reports whether the expression references no variables, e.g.
+// `current_timestamp() - duration("24h")`.
+func isConstantExpr(expr *exprpb.Expr) bool {
+	constant := true
+	walkExpr(expr, func(e *exprpb.Expr) {
+		switch e.ExprKind.(type) {
+		case *exprpb.Expr_IdentExpr, *exprpb.Expr_SelectExpr, *exprpb.Expr_ComprehensionExpr:
+			constant = false
+		}
+	})
+	return constant
+}
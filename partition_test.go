@@ -0,0 +1,293 @@
+package cel2sql_test
+
+import (
+	"errors"
+	"testing"
+
+	"cloud.google.com/go/bigquery"
+	"github.com/google/cel-go/cel"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/cockscomb/cel2sql"
+)
+
+func newPartitionedEnv(t *testing.T) *cel.Env {
+	t.Helper()
+	env, err := cel2sql.NewTableEnv(
+		map[string]*bigquery.TableMetadata{
+			"events": {
+				Schema: bigquery.Schema{
+					{Name: "name", Type: bigquery.StringFieldType},
+					{Name: "created_at", Type: bigquery.TimestampFieldType},
+				},
+				TimePartitioning:       &bigquery.TimePartitioning{Type: bigquery.DayPartitioningType, Field: "created_at"},
+				RequirePartitionFilter: true,
+			},
+			"shards": {
+				Schema: bigquery.Schema{
+					{Name: "name", Type: bigquery.StringFieldType},
+					{Name: "shard", Type: bigquery.IntegerFieldType},
+				},
+				RangePartitioning: &bigquery.RangePartitioning{
+					Field: "shard",
+					Range: &bigquery.RangePartitioningRange{Start: 0, End: 100, Interval: 10},
+				},
+			},
+			"logs": {
+				Schema: bigquery.Schema{
+					{Name: "name", Type: bigquery.StringFieldType},
+				},
+				TimePartitioning: &bigquery.TimePartitioning{Type: bigquery.DayPartitioningType},
+			},
+			"users": {
+				Schema: bigquery.Schema{
+					{Name: "name", Type: bigquery.StringFieldType},
+				},
+			},
+		},
+		cel2sql.TableVariable("event", "events"),
+		cel2sql.TableVariable("log", "logs"),
+		cel2sql.TableVariable("shard", "shards"),
+		cel2sql.TableVariable("user", "users"),
+	)
+	require.NoError(t, err)
+	return env
+}
+
+func TestPrunesPartitions(t *testing.T) {
+	env := newPartitionedEnv(t)
+
+	tests := []struct {
+		name     string
+		source   string
+		variable string
+		want     bool
+		wantErr  bool
+	}{
+		{
+			name:     "timeRange",
+			source:   `event.name == "a" && event.created_at >= timestamp("2021-01-01T00:00:00Z")`,
+			variable: "event",
+			want:     true,
+		},
+		{
+			name:     "constantExpression",
+			source:   `event.created_at >= current_timestamp() - duration("24h")`,
+			variable: "event",
+			want:     true,
+		},
+		{
+			name:     "disjunctionOfRanges",
+			source:   `event.created_at < timestamp("2021-01-01T00:00:00Z") || event.created_at > timestamp("2021-06-01T00:00:00Z")`,
+			variable: "event",
+			want:     true,
+		},
+		{
+			name:     "disjunctionWithOtherField",
+			source:   `event.created_at < timestamp("2021-01-01T00:00:00Z") || event.name == "a"`,
+			variable: "event",
+			want:     false,
+		},
+		{
+			name:     "otherField",
+			source:   `event.name == "a"`,
+			variable: "event",
+			want:     false,
+		},
+		{
+			name:     "notEquals",
+			source:   `event.created_at != timestamp("2021-01-01T00:00:00Z")`,
+			variable: "event",
+			want:     false,
+		},
+		{
+			name:     "comparedWithField",
+			source:   `event.created_at > timestamp(event.name)`,
+			variable: "event",
+			want:     false,
+		},
+		{
+			name:     "rangeIn",
+			source:   `shard.shard in [1, 2]`,
+			variable: "shard",
+			want:     true,
+		},
+		{
+			name:     "partitionTime",
+			source:   `log._PARTITIONTIME >= timestamp("2021-01-01T00:00:00Z")`,
+			variable: "log",
+			want:     true,
+		},
+		{
+			name:     "partitionDate",
+			source:   `log._PARTITIONDATE == current_date()`,
+			variable: "log",
+			want:     true,
+		},
+		{
+			name:     "notPartitioned",
+			source:   `user.name == "a"`,
+			variable: "user",
+			wantErr:  true,
+		},
+		{
+			name:     "unknownVariable",
+			source:   `user.name == "a"`,
+			variable: "unknown",
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ast, issues := env.Compile(tt.source)
+			require.Empty(t, issues)
+
+			got, err := cel2sql.PrunesPartitions(env, ast, tt.variable)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestEnforcePartitionFilter(t *testing.T) {
+	env := newPartitionedEnv(t)
+
+	tests := []struct {
+		name      string
+		source    string
+		variable  string
+		opts      []cel2sql.PartitionOption
+		want      string
+		wantError error
+	}{
+		{
+			name:     "pruned",
+			source:   `event.created_at >= timestamp("2021-01-01T00:00:00Z")`,
+			variable: "event",
+			want:     "`event`.`created_at` >= TIMESTAMP(\"2021-01-01T00:00:00Z\")",
+		},
+		{
+			name:      "required",
+			source:    `event.name == "a"`,
+			variable:  "event",
+			wantError: &cel2sql.PartitionFilterError{Variable: "event", Field: "created_at"},
+		},
+		{
+			name:     "defaultFilter",
+			source:   `event.name == "a" || event.name == "b"`,
+			variable: "event",
+			opts:     []cel2sql.PartitionOption{cel2sql.DefaultPartitionFilter(`event.created_at >= current_timestamp() - duration("24h")`)},
+			want:     "(`event`.`name` = \"a\" OR `event`.`name` = \"b\") AND `event`.`created_at` >= TIMESTAMP_SUB(CURRENT_TIMESTAMP(), INTERVAL 24 HOUR)",
+		},
+		{
+			name:      "defaultFilterNotPruning",
+			source:    `event.name == "a"`,
+			variable:  "event",
+			opts:      []cel2sql.PartitionOption{cel2sql.DefaultPartitionFilter(`event.name != ""`)},
+			wantError: errors.New("default partition filter does not prune the partitions: event.name != \"\""),
+		},
+		{
+			name:     "notRequired",
+			source:   `shard.name == "a"`,
+			variable: "shard",
+			want:     "`shard`.`name` = \"a\"",
+		},
+		{
+			name:      "rejectUnpruned",
+			source:    `shard.name == "a"`,
+			variable:  "shard",
+			opts:      []cel2sql.PartitionOption{cel2sql.RejectUnprunedPartitions()},
+			wantError: &cel2sql.PartitionFilterError{Variable: "shard", Field: "shard"},
+		},
+		{
+			name:     "notPartitioned",
+			source:   `user.name == "a"`,
+			variable: "user",
+			opts:     []cel2sql.PartitionOption{cel2sql.RejectUnprunedPartitions()},
+			want:     "`user`.`name` = \"a\"",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ast, issues := env.Compile(tt.source)
+			require.Empty(t, issues)
+
+			got, err := cel2sql.EnforcePartitionFilter(env, ast, tt.variable, tt.opts...)
+			if tt.wantError != nil {
+				assert.Equal(t, tt.wantError, err)
+				return
+			}
+			require.NoError(t, err)
+			sql, err := cel2sql.Convert(got)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, sql)
+		})
+	}
+}
+
+func TestConvert_pseudoColumns(t *testing.T) {
+	schema := bigquery.Schema{
+		{Name: "name", Type: bigquery.StringFieldType},
+	}
+	tables := map[string]*bigquery.TableMetadata{
+		"logs":     {Schema: schema, TimePartitioning: &bigquery.TimePartitioning{Type: bigquery.DayPartitioningType}},
+		"events_*": {Schema: schema},
+	}
+
+	tests := []struct {
+		name     string
+		opts     []cel2sql.EnvOption
+		source   string
+		conv     []cel2sql.ConvertOption
+		rowTable string
+		want     string
+	}{
+		{
+			name:   "partitionTime",
+			opts:   []cel2sql.EnvOption{cel2sql.TableVariable("log", "logs")},
+			source: `log._PARTITIONTIME >= timestamp("2021-01-01T00:00:00Z") && log.name == "a"`,
+			want:   "_PARTITIONTIME >= TIMESTAMP(\"2021-01-01T00:00:00Z\") AND `log`.`name` = \"a\"",
+		},
+		{
+			name:   "partitionDate",
+			opts:   []cel2sql.EnvOption{cel2sql.TableVariable("log", "logs")},
+			source: `log._PARTITIONDATE == date("2021-01-01")`,
+			conv:   []cel2sql.ConvertOption{cel2sql.TableSource("log", "l")},
+			want:   "_PARTITIONDATE = DATE(\"2021-01-01\")",
+		},
+		{
+			name:   "tableSuffix",
+			opts:   []cel2sql.EnvOption{cel2sql.TableVariable("event", "events_*")},
+			source: `event._TABLE_SUFFIX.startsWith("2021")`,
+			want:   "STARTS_WITH(_TABLE_SUFFIX, \"2021\")",
+		},
+		{
+			name:     "rowTable",
+			opts:     []cel2sql.EnvOption{cel2sql.RowTable("events_*")},
+			source:   `_TABLE_SUFFIX > "2021" && name == "a"`,
+			rowTable: "events_*",
+			want:     "_TABLE_SUFFIX > \"2021\" AND `e`.`name` = \"a\"",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env, err := cel2sql.NewTableEnv(tables, tt.opts...)
+			require.NoError(t, err)
+			ast, issues := env.Compile(tt.source)
+			require.Empty(t, issues)
+
+			conv := tt.conv
+			if tt.rowTable != "" {
+				conv = append(conv, cel2sql.RowColumns(env.TypeProvider(), tt.rowTable, "e"))
+			}
+			got, err := cel2sql.Convert(ast, conv...)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
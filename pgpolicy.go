@@ -0,0 +1,123 @@
+package cel2sql
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/google/cel-go/cel"
+	exprpb "google.golang.org/genproto/googleapis/api/expr/v1alpha1"
+)
+
+// PolicyCommand is the command to which a PostgreSQL policy applies.
+type PolicyCommand string
+
+// The commands of the policies.
+const (
+	PolicyAll    PolicyCommand = "ALL"
+	PolicySelect PolicyCommand = "SELECT"
+	PolicyInsert PolicyCommand = "INSERT"
+	PolicyUpdate PolicyCommand = "UPDATE"
+	PolicyDelete PolicyCommand = "DELETE"
+)
+
+// PostgresPolicy is a row security policy of a PostgreSQL table.
+type PostgresPolicy struct {
+	// Name is the name of the policy.
+	Name string
+	// Table is the qualified name of the table, e.g. `public.orders`.
+	Table string
+	// Variable is the table variable of the predicates, whose fields are the columns of the table.
+	Variable string
+	// Command is the command to which the policy applies, which is PolicyAll when empty.
+	Command PolicyCommand
+	// Roles are the roles to which the policy applies, which is PUBLIC when empty.
+	Roles []string
+	// Using selects the existing rows visible to the command. It is not rendered for INSERT.
+	Using *cel.Ast
+	// WithCheck checks the rows inserted or updated by the command. Using is checked instead when
+	// it is nil and the command is ALL or UPDATE. It is not rendered for SELECT and DELETE.
+	WithCheck *cel.Ast
+}
+
+// PostgresPolicyDDL returns the `CREATE POLICY` statement of the policy in PostgreSQL. The fields of
+// the table variable are rendered as bare column names, and the variables of SessionSettings as the
+// settings of the session, e.g. `row.tenant_id == tenant` as
+// `"tenant_id" = current_setting('app.tenant')`. The predicates must not reference other
+// variables, call functions other than the standard functions of CEL, or use comprehensions.
+func PostgresPolicyDDL(policy *PostgresPolicy, opts ...ConvertOption) (string, error) {
+	if !policyNameRegexp.MatchString(policy.Name) {
+		return "", fmt.Errorf("invalid policy name \"%s\"", policy.Name)
+	}
+	if policy.Table == "" {
+		return "", fmt.Errorf("table of policy \"%s\" is empty", policy.Name)
+	}
+	command := policy.Command
+	if command == "" {
+		command = PolicyAll
+	}
+	using, withCheck := policy.Using, policy.WithCheck
+	switch command {
+	case PolicyAll, PolicyUpdate:
+		if withCheck == nil {
+			withCheck = using
+		}
+	case PolicySelect, PolicyDelete:
+		if withCheck != nil {
+			return "", fmt.Errorf("policy \"%s\" for %s cannot have WITH CHECK", policy.Name, command)
+		}
+	case PolicyInsert:
+		if using != nil {
+			return "", fmt.Errorf("policy \"%s\" for %s cannot have USING", policy.Name, command)
+		}
+	default:
+		return "", fmt.Errorf("invalid command of policy \"%s\": %s", policy.Name, command)
+	}
+	if using == nil && withCheck == nil {
+		return "", fmt.Errorf("policy \"%s\" has no predicate", policy.Name)
+	}
+
+	opts = append(append([]ConvertOption{}, opts...), SQLDialect(PostgreSQL))
+	con := newConverter(&exprpb.CheckedExpr{}, opts)
+	var b strings.Builder
+	b.WriteString("CREATE POLICY ")
+	b.WriteString(con.quoteIdentifier(policy.Name))
+	b.WriteString(" ON ")
+	b.WriteString(con.quoteQualifiedName(policy.Table))
+	b.WriteString(" FOR ")
+	b.WriteString(string(command))
+	b.WriteString(" TO ")
+	if len(policy.Roles) == 0 {
+		b.WriteString("PUBLIC")
+	} else {
+		roles := make([]string, len(policy.Roles))
+		for i, role := range policy.Roles {
+			roles[i] = con.quoteIdentifier(role)
+		}
+		b.WriteString(strings.Join(roles, ", "))
+	}
+	for _, clause := range []struct {
+		keyword   string
+		predicate *cel.Ast
+	}{
+		{keyword: "USING", predicate: using},
+		{keyword: "WITH CHECK", predicate: withCheck},
+	} {
+		if clause.predicate == nil {
+			continue
+		}
+		sql, err := convertPolicyPredicate(clause.predicate, policy.Variable, isPostgresPolicyOverload, opts)
+		if err != nil {
+			return "", fmt.Errorf("policy \"%s\": %s: %w", policy.Name, clause.keyword, err)
+		}
+		b.WriteString(" ")
+		b.WriteString(clause.keyword)
+		b.WriteString(" (")
+		b.WriteString(sql)
+		b.WriteString(")")
+	}
+	return b.String(), nil
+}
+
+func isPostgresPolicyOverload(overloadID string) bool {
+	return standardOverloads[overloadID]
+}
@@ -0,0 +1,173 @@
+package cel2sql_test
+
+import (
+	"testing"
+
+	"cloud.google.com/go/bigquery"
+	"github.com/google/cel-go/cel"
+	"github.com/google/cel-go/checker/decls"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/cockscomb/cel2sql"
+	"github.com/cockscomb/cel2sql/test"
+)
+
+func TestPostgresPolicyDDL(t *testing.T) {
+	env, err := cel2sql.NewEnv(
+		map[string]bigquery.Schema{
+			"wikipedia": test.NewWikipediaTableMetadata().Schema,
+		},
+		cel2sql.TableVariable("page", "wikipedia"),
+		cel2sql.Declarations(
+			decls.NewVar("tenant", decls.String),
+			decls.NewVar("user_id", decls.Int),
+		),
+	)
+	require.NoError(t, err)
+	compile := func(source string) *cel.Ast {
+		ast, issues := env.Compile(source)
+		require.Empty(t, issues)
+		return ast
+	}
+	settings := cel2sql.SessionSettings(map[string]string{"tenant": "app.tenant", "user_id": "app.user_id"})
+
+	tests := []struct {
+		name    string
+		policy  *cel2sql.PostgresPolicy
+		opts    []cel2sql.ConvertOption
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "all",
+			policy: &cel2sql.PostgresPolicy{
+				Name:     "tenant_isolation",
+				Table:    "public.wikipedia",
+				Variable: "page",
+				Using:    compile(`page.language == tenant`),
+			},
+			opts: []cel2sql.ConvertOption{settings},
+			want: `CREATE POLICY "tenant_isolation" ON "public"."wikipedia" FOR ALL TO PUBLIC USING ("language" = current_setting('app.tenant')) WITH CHECK ("language" = current_setting('app.tenant'))`,
+		},
+		{
+			name: "update",
+			policy: &cel2sql.PostgresPolicy{
+				Name:      "own_pages",
+				Table:     "wikipedia",
+				Variable:  "page",
+				Command:   cel2sql.PolicyUpdate,
+				Roles:     []string{"editor", "admin"},
+				Using:     compile(`page.contributor_id == user_id`),
+				WithCheck: compile(`page.contributor_id == user_id && !page.is_bot`),
+			},
+			opts: []cel2sql.ConvertOption{settings},
+			want: `CREATE POLICY "own_pages" ON "wikipedia" FOR UPDATE TO "editor", "admin" USING ("contributor_id" = CAST(current_setting('app.user_id') AS BIGINT)) WITH CHECK ("contributor_id" = CAST(current_setting('app.user_id') AS BIGINT) AND NOT "is_bot")`,
+		},
+		{
+			name: "select",
+			policy: &cel2sql.PostgresPolicy{
+				Name:     "public_pages",
+				Table:    "wikipedia",
+				Variable: "page",
+				Command:  cel2sql.PolicySelect,
+				Using:    compile(`page.title.startsWith("Public:") || has(page.comment)`),
+			},
+			want: `CREATE POLICY "public_pages" ON "wikipedia" FOR SELECT TO PUBLIC USING (STARTS_WITH("title", 'Public:') OR "comment" IS NOT NULL)`,
+		},
+		{
+			name: "insert",
+			policy: &cel2sql.PostgresPolicy{
+				Name:      "no_bots",
+				Table:     "wikipedia",
+				Variable:  "page",
+				Command:   cel2sql.PolicyInsert,
+				WithCheck: compile(`!page.is_bot`),
+			},
+			want: `CREATE POLICY "no_bots" ON "wikipedia" FOR INSERT TO PUBLIC WITH CHECK (NOT "is_bot")`,
+		},
+		{
+			name: "insertWithUsing",
+			policy: &cel2sql.PostgresPolicy{
+				Name:     "no_bots",
+				Table:    "wikipedia",
+				Variable: "page",
+				Command:  cel2sql.PolicyInsert,
+				Using:    compile(`!page.is_bot`),
+			},
+			wantErr: true,
+		},
+		{
+			name: "selectWithCheck",
+			policy: &cel2sql.PostgresPolicy{
+				Name:      "no_bots",
+				Table:     "wikipedia",
+				Variable:  "page",
+				Command:   cel2sql.PolicySelect,
+				WithCheck: compile(`!page.is_bot`),
+			},
+			wantErr: true,
+		},
+		{
+			name: "noPredicate",
+			policy: &cel2sql.PostgresPolicy{
+				Name:     "empty",
+				Table:    "wikipedia",
+				Variable: "page",
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalidCommand",
+			policy: &cel2sql.PostgresPolicy{
+				Name:     "policy",
+				Table:    "wikipedia",
+				Variable: "page",
+				Command:  "TRUNCATE",
+				Using:    compile(`!page.is_bot`),
+			},
+			wantErr: true,
+		},
+		{
+			name: "notSessionSetting",
+			policy: &cel2sql.PostgresPolicy{
+				Name:     "policy",
+				Table:    "wikipedia",
+				Variable: "page",
+				Using:    compile(`page.language == tenant`),
+			},
+			wantErr: true,
+		},
+		{
+			name: "sqltypes",
+			policy: &cel2sql.PostgresPolicy{
+				Name:     "policy",
+				Table:    "wikipedia",
+				Variable: "page",
+				Using:    compile(`page.timestamp < int(current_timestamp())`),
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalidName",
+			policy: &cel2sql.PostgresPolicy{
+				Name:     "drop table",
+				Table:    "wikipedia",
+				Variable: "page",
+				Using:    compile(`!page.is_bot`),
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := cel2sql.PostgresPolicyDDL(tt.policy, tt.opts...)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
@@ -0,0 +1,122 @@
+package cel2sql
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/google/cel-go/cel"
+	"github.com/google/cel-go/common/operators"
+	exprpb "google.golang.org/genproto/googleapis/api/expr/v1alpha1"
+)
+
+type rowPolicy struct {
+	variable  string
+	predicate *cel.Ast
+}
+
+// RowPolicy registers the mandatory predicate on the rows of the table variable, e.g.
+// `row.tenant_id == tenant` for a table shared by tenants. The predicate must be bool and checked
+// in the same environment as the filters.
+//
+// The predicates are conjoined with every filter converted by Convert, ConvertFilter and
+// BuildQuery, even if the filter does not reference the variable.
+func RowPolicy(variable string, predicate *cel.Ast) ConvertOption {
+	return func(con *converter) {
+		con.rowPolicies = append(con.rowPolicies, rowPolicy{variable: variable, predicate: predicate})
+	}
+}
+
+// Filter is a condition converted by ConvertFilter.
+type Filter struct {
+	// SQL is the condition conjoining the predicates of the row policies and the filter.
+	SQL string
+	// Injected are the predicates of the row policies conjoined with the filter, in the order of
+	// registration.
+	Injected []InjectedPredicate
+}
+
+// InjectedPredicate is a predicate of a row policy conjoined with a filter.
+type InjectedPredicate struct {
+	Variable string
+	SQL      string
+}
+
+// ConvertFilter converts the filter conjoined with the predicates of the row policies, which are
+// reported for auditing. The predicates precede the filter, and each of them is parenthesized when
+// the precedence requires it, e.g. the filter `a || b` as `<predicate> AND (a OR b)`, so that the
+// filter cannot escape the predicates.
+func ConvertFilter(ast *cel.Ast, opts ...ConvertOption) (*Filter, error) {
+	checkedExpr, err := cel.AstToCheckedExpr(ast)
+	if err != nil {
+		return nil, err
+	}
+	return convertFilter(checkedExpr, opts)
+}
+
+// convertFilter converts the checked filter, which may be nil when there are only the predicates
+// of the row policies.
+func convertFilter(checkedExpr *exprpb.CheckedExpr, opts []ConvertOption) (*Filter, error) {
+	filter := &Filter{}
+	var conjunction strings.Builder
+	con := newConverter(&exprpb.CheckedExpr{}, opts)
+	for _, policy := range con.rowPolicies {
+		if policy.predicate.ResultType().GetPrimitive() != exprpb.Type_BOOL {
+			return nil, fmt.Errorf("row policy of \"%s\" must be bool but %s", policy.variable, cel.FormatType(policy.predicate.ResultType()))
+		}
+		predicate, err := cel.AstToCheckedExpr(policy.predicate)
+		if err != nil {
+			return nil, err
+		}
+		// the predicates are those of the service, which the operators allowed for the filters do
+		// not restrict.
+		policyConverter := newConverter(predicate, opts)
+		policyConverter.allowedOperators = nil
+		sql, err := policyConverter.convert(predicate.GetExpr())
+		if err != nil {
+			return nil, fmt.Errorf("row policy of \"%s\": %w", policy.variable, err)
+		}
+		writeConjunct(&conjunction, sql, predicate.GetExpr())
+		filter.Injected = append(filter.Injected, InjectedPredicate{Variable: policy.variable, SQL: sql})
+	}
+	if checkedExpr == nil {
+		filter.SQL = conjunction.String()
+		if err := con.checkLength(len(filter.SQL)); err != nil {
+			return nil, err
+		}
+		return filter, nil
+	}
+	sql, err := newConverter(checkedExpr, opts).convert(checkedExpr.GetExpr())
+	if err != nil {
+		return nil, err
+	}
+	if len(filter.Injected) == 0 {
+		filter.SQL = sql
+		return filter, nil
+	}
+	if typ := checkedExpr.GetTypeMap()[checkedExpr.GetExpr().GetId()]; typ.GetPrimitive() != exprpb.Type_BOOL {
+		return nil, fmt.Errorf("filter must be bool but %s", cel.FormatType(typ))
+	}
+	writeConjunct(&conjunction, sql, checkedExpr.GetExpr())
+	filter.SQL = conjunction.String()
+	// the limit applies to the whole conjunction as well as to each of the conjuncts.
+	if err := con.checkLength(len(filter.SQL)); err != nil {
+		return nil, err
+	}
+	return filter, nil
+}
+
+// writeConjunct appends the SQL of the expression to the conjunction, parenthesized as the operand
+// of AND.
+func writeConjunct(conjunction *strings.Builder, sql string, expr *exprpb.Expr) {
+	if conjunction.Len() > 0 {
+		conjunction.WriteString(" AND ")
+	}
+	nested := isComplexOperatorWithRespectTo(operators.LogicalAnd, expr)
+	if nested {
+		conjunction.WriteString("(")
+	}
+	conjunction.WriteString(sql)
+	if nested {
+		conjunction.WriteString(")")
+	}
+}
@@ -0,0 +1,146 @@
+package cel2sql_test
+
+import (
+	"testing"
+
+	"cloud.google.com/go/bigquery"
+	"github.com/google/cel-go/cel"
+	"github.com/google/cel-go/checker/decls"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/cockscomb/cel2sql"
+	"github.com/cockscomb/cel2sql/test"
+)
+
+func TestConvertFilter(t *testing.T) {
+	env, err := cel2sql.NewEnv(
+		map[string]bigquery.Schema{
+			"wikipedia": test.NewWikipediaTableMetadata().Schema,
+		},
+		cel2sql.TableVariable("page", "wikipedia"),
+		cel2sql.Declarations(decls.NewVar("language", decls.String)),
+	)
+	require.NoError(t, err)
+	compile := func(source string) *cel.Ast {
+		ast, issues := env.Compile(source)
+		require.Empty(t, issues)
+		return ast
+	}
+	policy := cel2sql.RowPolicy("page", compile(`page.language == language`))
+
+	tests := []struct {
+		name         string
+		source       string
+		opts         []cel2sql.ConvertOption
+		want         string
+		wantInjected []cel2sql.InjectedPredicate
+		wantErr      bool
+	}{
+		{
+			name:   "noPolicy",
+			source: `page.is_bot || page.is_minor`,
+			want:   "`page`.`is_bot` OR `page`.`is_minor`",
+		},
+		{
+			name:   "comparison",
+			source: `page.id > 1`,
+			opts:   []cel2sql.ConvertOption{policy},
+			want:   "`page`.`language` = `language` AND `page`.`id` > 1",
+			wantInjected: []cel2sql.InjectedPredicate{
+				{Variable: "page", SQL: "`page`.`language` = `language`"},
+			},
+		},
+		{
+			name:   "disjunction",
+			source: `page.is_bot || page.language != language`,
+			opts:   []cel2sql.ConvertOption{policy},
+			want:   "`page`.`language` = `language` AND (`page`.`is_bot` OR `page`.`language` != `language`)",
+			wantInjected: []cel2sql.InjectedPredicate{
+				{Variable: "page", SQL: "`page`.`language` = `language`"},
+			},
+		},
+		{
+			name:   "conditional",
+			source: `page.is_bot ? true : page.is_minor`,
+			opts:   []cel2sql.ConvertOption{policy},
+			want:   "`page`.`language` = `language` AND (IF(`page`.`is_bot`, TRUE, `page`.`is_minor`))",
+			wantInjected: []cel2sql.InjectedPredicate{
+				{Variable: "page", SQL: "`page`.`language` = `language`"},
+			},
+		},
+		{
+			name:   "multiplePolicies",
+			source: `true`,
+			opts: []cel2sql.ConvertOption{
+				policy,
+				cel2sql.RowPolicy("page", compile(`!page.is_redirect || page.id == 0`)),
+				cel2sql.QueryParameters(map[string]interface{}{"language": "en"}),
+			},
+			want: "`page`.`language` = @language AND (NOT `page`.`is_redirect` OR `page`.`id` = 0) AND TRUE",
+			wantInjected: []cel2sql.InjectedPredicate{
+				{Variable: "page", SQL: "`page`.`language` = @language"},
+				{Variable: "page", SQL: "NOT `page`.`is_redirect` OR `page`.`id` = 0"},
+			},
+		},
+		{
+			name:   "tableSource",
+			source: `page.id > 1`,
+			opts:   []cel2sql.ConvertOption{policy, cel2sql.TableSource("page", "p")},
+			want:   "`p`.`language` = `language` AND `p`.`id` > 1",
+			wantInjected: []cel2sql.InjectedPredicate{
+				{Variable: "page", SQL: "`p`.`language` = `language`"},
+			},
+		},
+		{
+			name:    "notBool",
+			source:  `page.id`,
+			opts:    []cel2sql.ConvertOption{policy},
+			wantErr: true,
+		},
+		{
+			name:    "policyNotBool",
+			source:  `page.is_bot`,
+			opts:    []cel2sql.ConvertOption{cel2sql.RowPolicy("page", compile(`page.language`))},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := cel2sql.ConvertFilter(compile(tt.source), tt.opts...)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got.SQL)
+			assert.Equal(t, tt.wantInjected, got.Injected)
+
+			sql, err := cel2sql.Convert(compile(tt.source), tt.opts...)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, sql)
+		})
+	}
+}
+
+func TestBuildQuery_rowPolicy(t *testing.T) {
+	env, err := cel2sql.NewEnv(
+		map[string]bigquery.Schema{
+			"wikipedia": test.NewWikipediaTableMetadata().Schema,
+		},
+		cel2sql.TableVariable("page", "wikipedia"),
+	)
+	require.NoError(t, err)
+	predicate, issues := env.Compile(`page.language == "en"`)
+	require.Empty(t, issues)
+	filter, issues := env.Compile(`page.is_bot || page.is_minor`)
+	require.Empty(t, issues)
+
+	got, err := cel2sql.BuildQuery(env, &cel2sql.Query{Variable: "page", Fields: []string{"title"}}, cel2sql.RowPolicy("page", predicate))
+	require.NoError(t, err)
+	assert.Equal(t, "SELECT `page`.`title` FROM `wikipedia` AS `page` WHERE `page`.`language` = \"en\"", got)
+
+	got, err = cel2sql.BuildQuery(env, &cel2sql.Query{Variable: "page", Fields: []string{"title"}, Filter: filter}, cel2sql.RowPolicy("page", predicate))
+	require.NoError(t, err)
+	assert.Equal(t, "SELECT `page`.`title` FROM `wikipedia` AS `page` WHERE `page`.`language` = \"en\" AND (`page`.`is_bot` OR `page`.`is_minor`)", got)
+}
@@ -0,0 +1,80 @@
+package cel2sql
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/google/cel-go/common/types/ref"
+	exprpb "google.golang.org/genproto/googleapis/api/expr/v1alpha1"
+
+	"github.com/cockscomb/cel2sql/composite"
+)
+
+// PolicyTagError is returned when an expression references a field restricted by policy tags
+// which the caller is not allowed to read.
+type PolicyTagError struct {
+	Location
+	TypeName   string
+	Field      string
+	PolicyTags []string
+}
+
+func (e *PolicyTagError) Error() string {
+	return fmt.Sprintf("%sfield \"%s\" of \"%s\" is restricted by policy tags: %s", e.prefix(), e.Field, e.TypeName, strings.Join(e.PolicyTags, ", "))
+}
+
+// EnforcePolicyTags rejects the expressions referencing the fields restricted by the type
+// provider, e.g. by bq.AllowedPolicyTags, with *PolicyTagError. The fields are rejected wherever
+// they are referenced, including in comprehensions and has(), and a RECORD field is rejected
+// when it is referenced as a whole, e.g. as the range of a comprehension, and any of its nested
+// fields is restricted. The provider
+// restricts nothing unless it implements composite.PolicyTagger.
+func EnforcePolicyTags(provider ref.TypeProvider) ConvertOption {
+	return func(con *converter) {
+		con.policyTagger, _ = provider.(composite.PolicyTagger)
+	}
+}
+
+// checkPolicyTags returns *PolicyTagError if the expression references a restricted field.
+func (con *converter) checkPolicyTags(expr *exprpb.Expr) error {
+	if con.policyTagger == nil {
+		return nil
+	}
+	// the fields of a record selected by another select are checked by that select.
+	selected := map[int64]bool{}
+	walkExpr(expr, func(e *exprpb.Expr) {
+		if operand := e.GetSelectExpr().GetOperand(); operand != nil {
+			selected[operand.GetId()] = true
+		}
+	})
+	var err error
+	walkExpr(expr, func(e *exprpb.Expr) {
+		if err != nil {
+			return
+		}
+		if selected[e.GetId()] && con.getType(e).GetMessageType() != "" {
+			return
+		}
+		var typeName, field string
+		switch e.ExprKind.(type) {
+		case *exprpb.Expr_SelectExpr:
+			typeName = con.getType(e.GetSelectExpr().GetOperand()).GetMessageType()
+			field = e.GetSelectExpr().GetField()
+		case *exprpb.Expr_IdentExpr:
+			if !con.rowColumns.isColumn(e.GetIdentExpr().GetName()) {
+				return
+			}
+			typeName = con.rowColumns.table
+			field = e.GetIdentExpr().GetName()
+		default:
+			return
+		}
+		if typeName == "" {
+			return
+		}
+		if policyTags := con.policyTagger.RestrictedPolicyTags(typeName, field); len(policyTags) > 0 {
+			err = &PolicyTagError{Location: Location{ExprID: e.GetId()}, TypeName: typeName, Field: field, PolicyTags: policyTags}
+		}
+	})
+	return err
+}
@@ -0,0 +1,170 @@
+package cel2sql_test
+
+import (
+	"errors"
+	"testing"
+
+	"cloud.google.com/go/bigquery"
+	"github.com/google/cel-go/checker/decls"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	exprpb "google.golang.org/genproto/googleapis/api/expr/v1alpha1"
+
+	"github.com/cockscomb/cel2sql"
+	"github.com/cockscomb/cel2sql/bq"
+)
+
+func TestEnforcePolicyTags(t *testing.T) {
+	const piiTag = "projects/p/locations/us/taxonomies/1/policyTags/pii"
+	schemas := map[string]bigquery.Schema{
+		"customers": {
+			{Name: "name", Type: bigquery.StringFieldType},
+			{Name: "email", Type: bigquery.StringFieldType, PolicyTags: &bigquery.PolicyTagList{Names: []string{piiTag}}},
+			{Name: "contacts", Type: bigquery.RecordFieldType, Repeated: true, Schema: bigquery.Schema{
+				{Name: "kind", Type: bigquery.StringFieldType},
+				{Name: "phone", Type: bigquery.StringFieldType, PolicyTags: &bigquery.PolicyTagList{Names: []string{piiTag}}},
+			}},
+			{Name: "address", Type: bigquery.RecordFieldType, Schema: bigquery.Schema{
+				{Name: "country", Type: bigquery.StringFieldType},
+				{Name: "street", Type: bigquery.StringFieldType, PolicyTags: &bigquery.PolicyTagList{Names: []string{piiTag}}},
+			}},
+		},
+	}
+	env, err := cel2sql.NewEnv(schemas,
+		cel2sql.TableVariable("customer", "customers"),
+		cel2sql.ProviderOptions(bq.AllowedPolicyTags()),
+	)
+	require.NoError(t, err)
+	rowEnv, err := cel2sql.NewEnv(schemas,
+		cel2sql.RowTable("customers"),
+		cel2sql.ProviderOptions(bq.AllowedPolicyTags()),
+	)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name      string
+		rowTable  bool
+		source    string
+		want      string
+		wantField string
+	}{
+		{
+			name:   "allowed",
+			source: `customer.name == "Alice" && customer.address.country == "US"`,
+			want:   "`customer`.`name` = \"Alice\" AND `customer`.`address`.`country` = \"US\"",
+		},
+		{
+			name:   "hasAllowed",
+			source: `has(customer.address.country)`,
+			want:   "`customer`.`address`.`country` IS NOT NULL",
+		},
+		{
+			name:      "restricted",
+			source:    `customer.email.endsWith("@example.com")`,
+			wantField: "email",
+		},
+		{
+			name:      "has",
+			source:    `has(customer.email)`,
+			wantField: "email",
+		},
+		{
+			name:      "nested",
+			source:    `customer.address.street == "Main St"`,
+			wantField: "street",
+		},
+		{
+			name:      "record",
+			source:    `customer.address == customer.address`,
+			wantField: "address",
+		},
+		{
+			name:      "comprehension",
+			source:    `["alice@example.com", "bob@example.com"].exists(e, e == customer.email)`,
+			wantField: "email",
+		},
+		{
+			name:      "comprehensionRange",
+			source:    `customer.contacts.exists(c, c.kind == "mobile")`,
+			wantField: "contacts",
+		},
+		{
+			name:      "rowColumn",
+			rowTable:  true,
+			source:    `name == "Alice" || email == "alice@example.com"`,
+			wantField: "email",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := env
+			opts := []cel2sql.ConvertOption{cel2sql.EnforcePolicyTags(env.TypeProvider())}
+			if tt.rowTable {
+				e = rowEnv
+				opts = append(opts, cel2sql.RowColumns(rowEnv.TypeProvider(), "customers", ""))
+			}
+			ast, issues := e.Compile(tt.source)
+			require.Empty(t, issues)
+
+			got, err := cel2sql.Convert(ast, opts...)
+			if tt.wantField != "" {
+				var policyTagErr *cel2sql.PolicyTagError
+				require.True(t, errors.As(err, &policyTagErr), "%v", err)
+				assert.Equal(t, tt.wantField, policyTagErr.Field)
+				assert.Equal(t, []string{piiTag}, policyTagErr.PolicyTags)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestSplitFilter_policyTags(t *testing.T) {
+	const piiTag = "projects/p/locations/us/taxonomies/1/policyTags/pii"
+	env, err := cel2sql.NewEnv(
+		map[string]bigquery.Schema{
+			"customers": {
+				{Name: "name", Type: bigquery.StringFieldType},
+				{Name: "email", Type: bigquery.StringFieldType, PolicyTags: &bigquery.PolicyTagList{Names: []string{piiTag}}},
+			},
+		},
+		cel2sql.TableVariable("customer", "customers"),
+		cel2sql.ProviderOptions(bq.AllowedPolicyTags()),
+		cel2sql.Declarations(
+			decls.NewFunction("isPalindrome",
+				decls.NewInstanceOverload("string_is_palindrome", []*exprpb.Type{decls.String}, decls.Bool),
+			),
+		),
+	)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name   string
+		source string
+	}{
+		{
+			name:   "pushable",
+			source: `customer.name == "Alice" && customer.email.endsWith("@example.com")`,
+		},
+		{
+			name:   "customFunction",
+			source: `customer.name == "Alice" && customer.email.isPalindrome()`,
+		},
+		{
+			name:   "comprehension",
+			source: `customer.name == "Alice" && ["a@example.com"].exists(e, e == customer.email)`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ast, issues := env.Compile(tt.source)
+			require.Empty(t, issues)
+
+			_, err := cel2sql.SplitFilter(ast, cel2sql.EnforcePolicyTags(env.TypeProvider()))
+			var policyTagErr *cel2sql.PolicyTagError
+			require.True(t, errors.As(err, &policyTagErr), "%v", err)
+			assert.Equal(t, "email", policyTagErr.Field)
+		})
+	}
+}
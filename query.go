@@ -0,0 +1,288 @@
+package cel2sql
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/google/cel-go/cel"
+	exprpb "google.golang.org/genproto/googleapis/api/expr/v1alpha1"
+)
+
+// Direction is the sort direction of an Ordering.
+type Direction int
+
+const (
+	Ascending Direction = iota
+	Descending
+)
+
+func (d Direction) String() string {
+	if d == Descending {
+		return "DESC"
+	}
+	return "ASC"
+}
+
+// NullOrder is the placement of NULLs in an Ordering.
+type NullOrder int
+
+const (
+	// NullsDefault places NULLs first in ascending order and last in descending order, as BigQuery
+	// does by default.
+	NullsDefault NullOrder = iota
+	NullsFirst
+	NullsLast
+)
+
+func (n NullOrder) String() string {
+	switch n {
+	case NullsFirst:
+		return "NULLS FIRST"
+	case NullsLast:
+		return "NULLS LAST"
+	}
+	return "NULLS DEFAULT"
+}
+
+// Ordering sorts rows by a field path of the table variable, e.g. `cell.page_count`.
+type Ordering struct {
+	Field     string
+	Direction Direction
+	Nulls     NullOrder
+}
+
+// nulls returns the placement of NULLs, resolving NullsDefault by the direction.
+func (ordering Ordering) nulls() NullOrder {
+	if ordering.Nulls != NullsDefault {
+		return ordering.Nulls
+	}
+	if ordering.Direction == Descending {
+		return NullsLast
+	}
+	return NullsFirst
+}
+
+// Query is a SELECT statement over the rows of a table variable.
+type Query struct {
+	// Variable is the table variable declared in the environment.
+	Variable string
+	// Table is the table of the FROM clause. It defaults to the type name of the variable.
+	Table string
+	// Filter is the condition of the WHERE clause.
+	Filter *cel.Ast
+	// Projection is a map literal whose values are selected as the columns named by its keys.
+	Projection *cel.Ast
+	// Fields are the field paths of the variable to select when Projection is nil. All columns
+	// are selected when neither is given.
+	Fields  []string
+	OrderBy []Ordering
+	Limit   int64
+	Offset  int64
+}
+
+// BuildQuery returns the SELECT statement of the query. The field paths of the query are
+// compiled in the environment, which must declare the table variable.
+//
+// The variable is aliased by its name unless TableSource is given for it. When TableSource renders
+// the variable as a qualified table name, the name is used in the FROM clause, aliased by its last
+// component.
+func BuildQuery(env *cel.Env, query *Query, opts ...ConvertOption) (string, error) {
+	variable, err := compileCheckedExpr(env, query.Variable)
+	if err != nil {
+		return "", err
+	}
+	typeName := variable.TypeMap[variable.Expr.GetId()].GetMessageType()
+	if typeName == "" {
+		return "", fmt.Errorf("variable \"%s\" is not a table", query.Variable)
+	}
+
+	// the fields of the environment are known to render has() of repeated fields.
+	opts = append([]ConvertOption{FieldTypes(env.TypeProvider())}, opts...)
+
+	var b strings.Builder
+	b.WriteString("SELECT ")
+	columns, err := query.columns(env, opts)
+	if err != nil {
+		return "", err
+	}
+	b.WriteString(columns)
+
+	b.WriteString(" FROM ")
+	table := query.Table
+	if table == "" {
+		table = typeName
+	}
+	con := newConverter(variable, opts)
+	alias := query.Variable
+	if source, found := con.tableSources[query.Variable]; found {
+		alias = source
+	}
+	if strings.Contains(alias, ".") {
+		table, alias = alias, tableAlias(alias)
+	}
+	b.WriteString(con.quoteQualifiedName(table))
+	if alias != "" {
+		b.WriteString(" AS ")
+		b.WriteString(con.quoteIdentifier(alias))
+	}
+
+	var filter *exprpb.CheckedExpr
+	if query.Filter != nil {
+		if query.Filter.ResultType().GetPrimitive() != exprpb.Type_BOOL {
+			return "", fmt.Errorf("filter must be bool but %s", cel.FormatType(query.Filter.ResultType()))
+		}
+		if filter, err = cel.AstToCheckedExpr(query.Filter); err != nil {
+			return "", err
+		}
+	}
+	// the row policies apply even without the filter.
+	where, err := convertFilter(filter, opts)
+	if err != nil {
+		return "", err
+	}
+	if where.SQL != "" {
+		b.WriteString(" WHERE ")
+		b.WriteString(where.SQL)
+	}
+
+	if len(query.OrderBy) > 0 {
+		orderBy, err := orderByClause(env, query.Variable, query.OrderBy, opts)
+		if err != nil {
+			return "", err
+		}
+		b.WriteString(" ")
+		b.WriteString(orderBy)
+	}
+
+	if query.Limit < 0 || query.Offset < 0 {
+		return "", fmt.Errorf("limit and offset must not be negative")
+	}
+	if query.Limit > 0 {
+		b.WriteString(" LIMIT ")
+		b.WriteString(strconv.FormatInt(query.Limit, 10))
+	}
+	if query.Offset > 0 {
+		if query.Limit == 0 {
+			return "", fmt.Errorf("offset requires limit")
+		}
+		b.WriteString(" OFFSET ")
+		b.WriteString(strconv.FormatInt(query.Offset, 10))
+	}
+	if err := con.checkLength(b.Len()); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+func (query *Query) columns(env *cel.Env, opts []ConvertOption) (string, error) {
+	if query.Projection != nil {
+		return convertProjection(query.Projection, opts)
+	}
+	if len(query.Fields) == 0 {
+		return "*", nil
+	}
+	columns := make([]string, len(query.Fields))
+	for i, field := range query.Fields {
+		column, err := convertField(env, query.Variable, field, opts)
+		if err != nil {
+			return "", err
+		}
+		columns[i] = column
+	}
+	return strings.Join(columns, ", "), nil
+}
+
+func convertProjection(projection *cel.Ast, opts []ConvertOption) (string, error) {
+	checkedExpr, err := cel.AstToCheckedExpr(projection)
+	if err != nil {
+		return "", err
+	}
+	s := checkedExpr.Expr.GetStructExpr()
+	if s == nil || s.GetMessageName() != "" || len(s.GetEntries()) == 0 {
+		return "", fmt.Errorf("projection must be a non-empty map literal")
+	}
+	con := newConverter(checkedExpr, opts)
+	columns := make([]string, len(s.GetEntries()))
+	for i, entry := range s.GetEntries() {
+		name, err := extractFieldName(entry.GetMapKey())
+		if err != nil {
+			return "", err
+		}
+		value, err := con.convert(entry.GetValue())
+		if err != nil {
+			return "", err
+		}
+		columns[i] = value + " AS " + con.quoteIdentifier(name)
+	}
+	return strings.Join(columns, ", "), nil
+}
+
+// OrderByClause returns the ORDER BY clause of the orderings. The field paths are compiled in the
+// environment as the fields of the variable, or as top-level identifiers when the variable is
+// empty. Fields which are repeated or not scalar cannot be ordered.
+//
+// The placement of NULLs is always explicit, e.g. `ASC NULLS FIRST`, so that the rows are ordered
+// the same in every dialect and as KeysetFilter expects.
+func OrderByClause(env *cel.Env, variable string, orderings []Ordering, opts ...ConvertOption) (string, error) {
+	return orderByClause(env, variable, orderings, opts)
+}
+
+func orderByClause(env *cel.Env, variable string, orderings []Ordering, opts []ConvertOption) (string, error) {
+	terms := make([]string, len(orderings))
+	for i, ordering := range orderings {
+		checkedExpr, err := compileField(env, variable, ordering.Field)
+		if err != nil {
+			return "", err
+		}
+		if err := validateOrderable(ordering.Field, checkedExpr.TypeMap[checkedExpr.Expr.GetId()]); err != nil {
+			return "", err
+		}
+		column, err := newConverter(checkedExpr, opts).convert(checkedExpr.Expr)
+		if err != nil {
+			return "", err
+		}
+		terms[i] = column + " " + ordering.Direction.String() + " " + ordering.nulls().String()
+	}
+	return "ORDER BY " + strings.Join(terms, ", "), nil
+}
+
+func validateOrderable(field string, typ *exprpb.Type) error {
+	switch typ.GetTypeKind().(type) {
+	case *exprpb.Type_ListType_:
+		return fmt.Errorf("repeated field \"%s\" cannot be ordered", field)
+	case *exprpb.Type_MapType_, *exprpb.Type_MessageType:
+		return fmt.Errorf("field \"%s\" of %s cannot be ordered", field, cel.FormatType(typ))
+	}
+	return nil
+}
+
+// compileField compiles the field path of the variable, e.g. `cell.page_count`. The path is a
+// top-level identifier when the variable is empty.
+func compileField(env *cel.Env, variable string, field string) (*exprpb.CheckedExpr, error) {
+	for _, name := range strings.Split(field, ".") {
+		if err := validateFieldName(name); err != nil {
+			return nil, err
+		}
+	}
+	if variable == "" {
+		return compileCheckedExpr(env, field)
+	}
+	return compileCheckedExpr(env, variable+"."+field)
+}
+
+func convertField(env *cel.Env, variable string, field string, opts []ConvertOption) (string, error) {
+	checkedExpr, err := compileField(env, variable, field)
+	if err != nil {
+		return "", err
+	}
+	return newConverter(checkedExpr, opts).convert(checkedExpr.Expr)
+}
+
+func compileCheckedExpr(env *cel.Env, source string) (*exprpb.CheckedExpr, error) {
+	ast, issues := env.Compile(source)
+	if issues != nil && issues.Err() != nil {
+		return nil, issues.Err()
+	}
+	return cel.AstToCheckedExpr(ast)
+}
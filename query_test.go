@@ -0,0 +1,252 @@
+package cel2sql_test
+
+import (
+	"testing"
+
+	"cloud.google.com/go/bigquery"
+	"github.com/google/cel-go/cel"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/cockscomb/cel2sql"
+	"github.com/cockscomb/cel2sql/test"
+)
+
+func TestBuildQuery(t *testing.T) {
+	env, err := cel2sql.NewEnv(
+		map[string]bigquery.Schema{
+			"trigrams":  test.NewTrigramsTableMetadata().Schema,
+			"wikipedia": test.NewWikipediaTableMetadata().Schema,
+		},
+		cel2sql.TableVariable("trigram", "trigrams"),
+		cel2sql.TableVariable("page", "wikipedia"),
+	)
+	require.NoError(t, err)
+	compile := func(source string) *cel.Ast {
+		ast, issues := env.Compile(source)
+		require.Empty(t, issues)
+		return ast
+	}
+
+	type args struct {
+		query *cel2sql.Query
+		opts  []cel2sql.ConvertOption
+	}
+	tests := []struct {
+		name    string
+		args    args
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "all",
+			args: args{
+				query: &cel2sql.Query{Variable: "page"},
+			},
+			want: "SELECT * FROM `wikipedia` AS `page`",
+		},
+		{
+			name: "filter",
+			args: args{
+				query: &cel2sql.Query{
+					Variable: "page",
+					Filter:   compile(`page.title.startsWith("a") && page.is_redirect == false`),
+				},
+			},
+			want: "SELECT * FROM `wikipedia` AS `page` WHERE STARTS_WITH(`page`.`title`, \"a\") AND `page`.`is_redirect` IS FALSE",
+		},
+		{
+			name: "fields",
+			args: args{
+				query: &cel2sql.Query{
+					Variable: "trigram",
+					Fields:   []string{"ngram", "first"},
+					OrderBy: []cel2sql.Ordering{
+						{Field: "first", Direction: cel2sql.Ascending},
+						{Field: "ngram", Direction: cel2sql.Descending},
+					},
+					Limit:  10,
+					Offset: 20,
+				},
+			},
+			want: "SELECT `trigram`.`ngram`, `trigram`.`first` FROM `trigrams` AS `trigram` ORDER BY `trigram`.`first` ASC NULLS FIRST, `trigram`.`ngram` DESC NULLS LAST LIMIT 10 OFFSET 20",
+		},
+		{
+			name: "projection",
+			args: args{
+				query: &cel2sql.Query{
+					Variable:   "page",
+					Projection: compile(`{"title": page.title, "length": size(page.title)}`),
+					Filter:     compile(`page.id > 100`),
+					Limit:      1,
+				},
+			},
+			want: "SELECT `page`.`title` AS `title`, LENGTH(`page`.`title`) AS `length` FROM `wikipedia` AS `page` WHERE `page`.`id` > 100 LIMIT 1",
+		},
+		{
+			name: "table",
+			args: args{
+				query: &cel2sql.Query{
+					Variable: "page",
+					Table:    "bigquery-public-data.samples.wikipedia",
+					Filter:   compile(`page.id > 100`),
+				},
+			},
+			want: "SELECT * FROM `bigquery-public-data`.`samples`.`wikipedia` AS `page` WHERE `page`.`id` > 100",
+		},
+		{
+			name: "hasRepeated",
+			args: args{
+				query: &cel2sql.Query{
+					Variable: "trigram",
+					Fields:   []string{"ngram"},
+					Filter:   compile(`has(trigram.cell)`),
+				},
+			},
+			want: "SELECT `trigram`.`ngram` FROM `trigrams` AS `trigram` WHERE ARRAY_LENGTH(`trigram`.`cell`) > 0",
+		},
+		{
+			name: "tableSource_alias",
+			args: args{
+				query: &cel2sql.Query{
+					Variable: "page",
+					Filter:   compile(`page.id > 100`),
+				},
+				opts: []cel2sql.ConvertOption{cel2sql.TableSource("page", "p")},
+			},
+			want: "SELECT * FROM `wikipedia` AS `p` WHERE `p`.`id` > 100",
+		},
+		{
+			name: "tableSource_unqualified",
+			args: args{
+				query: &cel2sql.Query{
+					Variable: "page",
+					Fields:   []string{"title"},
+					Filter:   compile(`page.id > 100`),
+				},
+				opts: []cel2sql.ConvertOption{cel2sql.TableSource("page", "")},
+			},
+			want: "SELECT `title` FROM `wikipedia` WHERE `id` > 100",
+		},
+		{
+			name: "tableSource_qualified",
+			args: args{
+				query: &cel2sql.Query{
+					Variable: "page",
+					Filter:   compile(`page.id > 100`),
+				},
+				opts: []cel2sql.ConvertOption{cel2sql.TableSource("page", "samples.wikipedia")},
+			},
+			want: "SELECT * FROM `samples`.`wikipedia` AS `wikipedia` WHERE `wikipedia`.`id` > 100",
+		},
+		{
+			name: "postgreSQL",
+			args: args{
+				query: &cel2sql.Query{
+					Variable:   "page",
+					Table:      "public.wikipedia",
+					Projection: compile(`{"title": page.title}`),
+					Filter:     compile(`page.id > 100`),
+					OrderBy: []cel2sql.Ordering{
+						{Field: "id"},
+						{Field: "title", Direction: cel2sql.Descending, Nulls: cel2sql.NullsFirst},
+					},
+				},
+				opts: []cel2sql.ConvertOption{cel2sql.SQLDialect(cel2sql.PostgreSQL)},
+			},
+			want: `SELECT "page"."title" AS "title" FROM "public"."wikipedia" AS "page" WHERE "page"."id" > 100 ORDER BY "page"."id" ASC NULLS FIRST, "page"."title" DESC NULLS FIRST`,
+		},
+		{
+			name: "postgreSQL_tableSource",
+			args: args{
+				query: &cel2sql.Query{
+					Variable: "page",
+					Fields:   []string{"title"},
+				},
+				opts: []cel2sql.ConvertOption{
+					cel2sql.SQLDialect(cel2sql.PostgreSQL),
+					cel2sql.TableSource("page", "public.wikipedia"),
+				},
+			},
+			want: `SELECT "wikipedia"."title" FROM "public"."wikipedia" AS "wikipedia"`,
+		},
+		{
+			name: "sqlLength",
+			args: args{
+				query: &cel2sql.Query{
+					Variable: "page",
+					Filter:   compile(`page.id > 100`),
+				},
+				opts: []cel2sql.ConvertOption{cel2sql.MaxSQLLength(40)},
+			},
+			wantErr: true,
+		},
+		{
+			name: "notTable",
+			args: args{
+				query: &cel2sql.Query{Variable: "MONTH"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "notBoolFilter",
+			args: args{
+				query: &cel2sql.Query{
+					Variable: "page",
+					Filter:   compile(`page.id`),
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "projectionNotMap",
+			args: args{
+				query: &cel2sql.Query{
+					Variable:   "page",
+					Projection: compile(`[page.title]`),
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalidField",
+			args: args{
+				query: &cel2sql.Query{
+					Variable: "page",
+					Fields:   []string{"title || true"},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "orderByRepeated",
+			args: args{
+				query: &cel2sql.Query{
+					Variable: "trigram",
+					OrderBy:  []cel2sql.Ordering{{Field: "cell"}},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "offsetWithoutLimit",
+			args: args{
+				query: &cel2sql.Query{
+					Variable: "page",
+					Offset:   10,
+				},
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := cel2sql.BuildQuery(env, tt.args.query, tt.args.opts...)
+			if !tt.wantErr && assert.NoError(t, err) {
+				assert.Equal(t, tt.want, got)
+			} else {
+				assert.Error(t, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,130 @@
+package cel2sql
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/google/cel-go/cel"
+	exprpb "google.golang.org/genproto/googleapis/api/expr/v1alpha1"
+)
+
+// RowAccessPolicy is a row access policy of a BigQuery table, which grants the access to the rows
+// selected by the predicate.
+type RowAccessPolicy struct {
+	// Name is the name of the policy.
+	Name string
+	// Table is the qualified name of the table, e.g. `project.dataset.table`.
+	Table string
+	// Variable is the table variable of the predicate, whose fields are the columns of the table.
+	Variable string
+	// Grantees are the members granted the access, e.g. `user:alice@example.com` or
+	// `group:sales@example.com`. All the users are granted when empty.
+	Grantees []string
+	// Predicate selects the rows which the grantees can access.
+	Predicate *cel.Ast
+}
+
+var policyNameRegexp = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+// rowAccessPolicyExcludedOverloads are the overloads which are convertible but not allowed in row
+// access policies, as they are not deterministic.
+var rowAccessPolicyExcludedOverloads = map[string]bool{
+	"current_date":              true,
+	"current_date_timezone":     true,
+	"current_time":              true,
+	"current_time_timezone":     true,
+	"current_datetime":          true,
+	"current_datetime_timezone": true,
+	"current_timestamp":         true,
+}
+
+// RowAccessPolicyDDL returns the `CREATE OR REPLACE ROW ACCESS POLICY` statement of the policy.
+// The fields of the table variable are rendered as bare column names as required by
+// `FILTER USING`. The predicate must not reference other variables, call functions other than the
+// deterministic functions of CEL and sqltypes, or use comprehensions, which BigQuery does not allow
+// in row access policies.
+func RowAccessPolicyDDL(policy *RowAccessPolicy, opts ...ConvertOption) (string, error) {
+	if !policyNameRegexp.MatchString(policy.Name) {
+		return "", fmt.Errorf("invalid row access policy name \"%s\"", policy.Name)
+	}
+	if policy.Table == "" {
+		return "", fmt.Errorf("table of row access policy \"%s\" is empty", policy.Name)
+	}
+	opts = append(append([]ConvertOption{}, opts...), SQLDialect(BigQuery))
+	filter, err := convertPolicyPredicate(policy.Predicate, policy.Variable, isRowAccessPolicyOverload, opts)
+	if err != nil {
+		return "", fmt.Errorf("row access policy \"%s\": %w", policy.Name, err)
+	}
+
+	con := newConverter(&exprpb.CheckedExpr{}, opts)
+	var b strings.Builder
+	b.WriteString("CREATE OR REPLACE ROW ACCESS POLICY ")
+	b.WriteString(policy.Name)
+	b.WriteString(" ON ")
+	b.WriteString(con.quoteQualifiedName(policy.Table))
+	if len(policy.Grantees) > 0 {
+		grantees := make([]string, len(policy.Grantees))
+		for i, grantee := range policy.Grantees {
+			grantees[i] = strconv.Quote(grantee)
+		}
+		b.WriteString(" GRANT TO (")
+		b.WriteString(strings.Join(grantees, ", "))
+		b.WriteString(")")
+	}
+	b.WriteString(" FILTER USING (")
+	b.WriteString(filter)
+	b.WriteString(")")
+	return b.String(), nil
+}
+
+func isRowAccessPolicyOverload(overloadID string) bool {
+	return sqlOverloads[overloadID] && !rowAccessPolicyExcludedOverloads[overloadID]
+}
+
+// convertPolicyPredicate converts the bool predicate over the table variable, whose fields are
+// rendered as bare column names. The predicate may reference the variables of the session settings
+// besides the table variable, and call only the allowed overloads.
+func convertPolicyPredicate(predicate *cel.Ast, variable string, allowed func(overloadID string) bool, opts []ConvertOption) (string, error) {
+	if predicate.ResultType().GetPrimitive() != exprpb.Type_BOOL {
+		return "", fmt.Errorf("predicate must be bool but %s", cel.FormatType(predicate.ResultType()))
+	}
+	checkedExpr, err := cel.AstToCheckedExpr(predicate)
+	if err != nil {
+		return "", err
+	}
+	sessionSettings := newConverter(&exprpb.CheckedExpr{}, opts).sessionSettings
+	var others []string
+	for name := range referencedVariables(checkedExpr) {
+		if _, found := sessionSettings[name]; !found && name != variable {
+			others = append(others, name)
+		}
+	}
+	if len(others) > 0 {
+		sort.Strings(others)
+		return "", fmt.Errorf("predicate cannot reference variables other than \"%s\": %s", variable, strings.Join(others, ", "))
+	}
+	var disallowed error
+	walkExpr(checkedExpr.GetExpr(), func(e *exprpb.Expr) {
+		if disallowed != nil {
+			return
+		}
+		switch e.ExprKind.(type) {
+		case *exprpb.Expr_ComprehensionExpr:
+			disallowed = fmt.Errorf("comprehensions are not allowed")
+		case *exprpb.Expr_CallExpr:
+			for _, id := range checkedExpr.GetReferenceMap()[e.GetId()].GetOverloadId() {
+				if !allowed(id) {
+					disallowed = fmt.Errorf("function \"%s\" is not allowed", e.GetCallExpr().GetFunction())
+				}
+			}
+		}
+	})
+	if disallowed != nil {
+		return "", disallowed
+	}
+	opts = append(append([]ConvertOption{}, opts...), TableSource(variable, ""))
+	return newConverter(checkedExpr, opts).convert(checkedExpr.GetExpr())
+}
@@ -0,0 +1,133 @@
+package cel2sql_test
+
+import (
+	"testing"
+
+	"cloud.google.com/go/bigquery"
+	"github.com/google/cel-go/cel"
+	"github.com/google/cel-go/checker/decls"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/cockscomb/cel2sql"
+	"github.com/cockscomb/cel2sql/bq"
+	"github.com/cockscomb/cel2sql/test"
+)
+
+func TestRowAccessPolicyDDL(t *testing.T) {
+	env, err := cel2sql.NewEnv(
+		map[string]bigquery.Schema{
+			"wikipedia": test.NewWikipediaTableMetadata().Schema,
+		},
+		cel2sql.TableVariable("page", "wikipedia"),
+		cel2sql.Declarations(decls.NewVar("tenant", decls.String)),
+	)
+	require.NoError(t, err)
+	compile := func(source string) *cel.Ast {
+		ast, issues := env.Compile(source)
+		require.Empty(t, issues)
+		return ast
+	}
+
+	tests := []struct {
+		name    string
+		policy  *cel2sql.RowAccessPolicy
+		opts    []cel2sql.ConvertOption
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "grantees",
+			policy: &cel2sql.RowAccessPolicy{
+				Name:      "english_pages",
+				Table:     "project.dataset.wikipedia",
+				Variable:  "page",
+				Grantees:  []string{"user:alice@example.com", "group:editors@example.com"},
+				Predicate: compile(`page.language == "en" && !page.is_bot`),
+			},
+			want: "CREATE OR REPLACE ROW ACCESS POLICY english_pages ON `project`.`dataset`.`wikipedia` GRANT TO (\"user:alice@example.com\", \"group:editors@example.com\") FILTER USING (`language` = \"en\" AND NOT `is_bot`)",
+		},
+		{
+			name: "allUsers",
+			policy: &cel2sql.RowAccessPolicy{
+				Name:      "public_pages",
+				Table:     "dataset.wikipedia",
+				Variable:  "page",
+				Predicate: compile(`page.title.startsWith("Public:") || has(page.comment)`),
+			},
+			want: "CREATE OR REPLACE ROW ACCESS POLICY public_pages ON `dataset`.`wikipedia` FILTER USING (STARTS_WITH(`title`, \"Public:\") OR `comment` IS NOT NULL)",
+		},
+		{
+			name: "columnNames",
+			policy: &cel2sql.RowAccessPolicy{
+				Name:      "policy",
+				Table:     "wikipedia",
+				Variable:  "page",
+				Predicate: compile(`page.language == "en"`),
+			},
+			opts: []cel2sql.ConvertOption{cel2sql.ColumnNames(bq.NameMap{"wikipedia": {"language": "lang"}})},
+			want: "CREATE OR REPLACE ROW ACCESS POLICY policy ON `wikipedia` FILTER USING (`lang` = \"en\")",
+		},
+		{
+			name: "otherVariable",
+			policy: &cel2sql.RowAccessPolicy{
+				Name:      "policy",
+				Table:     "wikipedia",
+				Variable:  "page",
+				Predicate: compile(`page.language == tenant`),
+			},
+			wantErr: true,
+		},
+		{
+			name: "nonDeterministic",
+			policy: &cel2sql.RowAccessPolicy{
+				Name:      "policy",
+				Table:     "wikipedia",
+				Variable:  "page",
+				Predicate: compile(`timestamp(page.timestamp) > current_timestamp()`),
+			},
+			wantErr: true,
+		},
+		{
+			name: "comprehension",
+			policy: &cel2sql.RowAccessPolicy{
+				Name:      "policy",
+				Table:     "wikipedia",
+				Variable:  "page",
+				Predicate: compile(`["en", "ja"].exists(l, l == page.language)`),
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalidName",
+			policy: &cel2sql.RowAccessPolicy{
+				Name:      "policy; DROP TABLE wikipedia",
+				Table:     "wikipedia",
+				Variable:  "page",
+				Predicate: compile(`page.language == "en"`),
+			},
+			wantErr: true,
+		},
+		{
+			name: "notBool",
+			policy: &cel2sql.RowAccessPolicy{
+				Name:      "policy",
+				Table:     "wikipedia",
+				Variable:  "page",
+				Predicate: compile(`page.language`),
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := cel2sql.RowAccessPolicyDDL(tt.policy, tt.opts...)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
@@ -0,0 +1,112 @@
+package cel2sql
+
+import (
+	"github.com/google/cel-go/cel"
+	"github.com/google/cel-go/checker"
+	"github.com/google/cel-go/parser"
+	exprpb "google.golang.org/genproto/googleapis/api/expr/v1alpha1"
+
+	"github.com/cockscomb/cel2sql/sqltypes"
+)
+
+// Split is a filter split into the conjuncts pushed down to SQL and the residual evaluated in Go.
+type Split struct {
+	// Filter is the conjunction of the conjuncts which can be converted to SQL. It is `true` when
+	// no conjunct can be converted.
+	Filter *cel.Ast
+	// Pushed are the CEL sources of the conjuncts in Filter.
+	Pushed []string
+	// Residual is the conjunction of the other conjuncts, or nil when all the conjuncts are pushed
+	// down. The rows selected by Filter must be filtered by Residual.
+	Residual *cel.Ast
+}
+
+// SplitFilter splits the top-level conjunction of the filter into the largest part which can be
+// converted to SQL with the options, and the residual. Conjuncts calling functions other than the
+// standard functions of CEL and sqltypes, e.g. custom functions, or using comprehensions are left
+// in the residual, as are those failing with UnsupportedExprError, UnsupportedFunctionError or
+// UnsupportedTypeError.
+//
+// The other errors, e.g. PolicyTagError, OperatorNotAllowedError or LimitError, are returned
+// whether or not the conjunct could be pushed down, so that the restrictions of the options cannot
+// be bypassed by evaluating the conjunct in Go. The limits apply to the whole filter rather than to
+// each conjunct.
+func SplitFilter(ast *cel.Ast, opts ...ConvertOption) (*Split, error) {
+	checkedExpr, err := cel.AstToCheckedExpr(ast)
+	if err != nil {
+		return nil, err
+	}
+	// the limits apply to the whole filter, which the conjuncts would pass one by one.
+	if err := newConverter(checkedExpr, opts).check(checkedExpr.GetExpr()); err != nil {
+		return nil, err
+	}
+	var pushed, residual []*exprpb.Expr
+	split := &Split{}
+	for _, conjunct := range conjuncts(checkedExpr.GetExpr()) {
+		if !isSQLFunctionsOnly(checkedExpr, conjunct) {
+			residual = append(residual, conjunct)
+			continue
+		}
+		if _, err := newConverter(checkedExpr, opts).convert(conjunct); err != nil {
+			if !isUnsupportedError(err) {
+				return nil, err
+			}
+			residual = append(residual, conjunct)
+			continue
+		}
+		source, err := parser.Unparse(conjunct, checkedExpr.GetSourceInfo())
+		if err != nil {
+			return nil, err
+		}
+		pushed = append(pushed, conjunct)
+		split.Pushed = append(split.Pushed, source)
+	}
+	split.Filter = cel.CheckedExprToAst(conjoin(checkedExpr, pushed))
+	if len(residual) > 0 {
+		split.Residual = cel.CheckedExprToAst(conjoin(checkedExpr, residual))
+	}
+	return split, nil
+}
+
+// Program returns the program evaluating the residual over the fetched rows. It evaluates to
+// true when there is no residual. The options provide the implementations of custom functions.
+func (s *Split) Program(env *cel.Env, opts ...cel.ProgramOption) (cel.Program, error) {
+	residual := s.Residual
+	if residual == nil {
+		ast, issues := env.Compile("true")
+		if issues != nil && issues.Err() != nil {
+			return nil, issues.Err()
+		}
+		residual = ast
+	}
+	return env.Program(residual, opts...)
+}
+
+var sqlOverloads = func() map[string]bool {
+	ids := map[string]bool{}
+	for _, declarations := range [][]*exprpb.Decl{checker.StandardDeclarations(), sqltypes.Declarations} {
+		for _, declaration := range declarations {
+			for _, overload := range declaration.GetFunction().GetOverloads() {
+				ids[overload.GetOverloadId()] = true
+			}
+		}
+	}
+	return ids
+}()
+
+// isSQLFunctionsOnly reports whether the expression calls only the overloads which can be
+// converted to SQL.
+func isSQLFunctionsOnly(checkedExpr *exprpb.CheckedExpr, expr *exprpb.Expr) bool {
+	supported := true
+	walkExpr(expr, func(e *exprpb.Expr) {
+		if e.GetCallExpr() == nil {
+			return
+		}
+		for _, id := range checkedExpr.GetReferenceMap()[e.GetId()].GetOverloadId() {
+			if !sqlOverloads[id] {
+				supported = false
+			}
+		}
+	})
+	return supported
+}
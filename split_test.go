@@ -0,0 +1,131 @@
+package cel2sql_test
+
+import (
+	"testing"
+
+	"cloud.google.com/go/bigquery"
+	"github.com/google/cel-go/cel"
+	"github.com/google/cel-go/checker/decls"
+	"github.com/google/cel-go/common/types"
+	"github.com/google/cel-go/common/types/ref"
+	"github.com/google/cel-go/interpreter/functions"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	exprpb "google.golang.org/genproto/googleapis/api/expr/v1alpha1"
+
+	"github.com/cockscomb/cel2sql"
+	"github.com/cockscomb/cel2sql/test"
+)
+
+func TestSplitFilter(t *testing.T) {
+	env, err := cel2sql.NewEnv(
+		map[string]bigquery.Schema{
+			"trigrams":  test.NewTrigramsTableMetadata().Schema,
+			"wikipedia": test.NewWikipediaTableMetadata().Schema,
+		},
+		cel2sql.TableVariable("trigram", "trigrams"),
+		cel2sql.TableVariable("page", "wikipedia"),
+		cel2sql.Declarations(
+			decls.NewFunction("isPalindrome",
+				decls.NewInstanceOverload("string_is_palindrome", []*exprpb.Type{decls.String}, decls.Bool),
+			),
+		),
+	)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name         string
+		source       string
+		want         string
+		wantPushed   []string
+		wantResidual bool
+	}{
+		{
+			name:       "allPushed",
+			source:     `page.id > 10 && page.title.startsWith("a")`,
+			want:       "`page`.`id` > 10 AND STARTS_WITH(`page`.`title`, \"a\")",
+			wantPushed: []string{`page.id > 10`, `page.title.startsWith("a")`},
+		},
+		{
+			name:         "customFunction",
+			source:       `page.id > 10 && page.title.isPalindrome() && page.wp_namespace == 0`,
+			want:         "`page`.`id` > 10 AND `page`.`wp_namespace` = 0",
+			wantPushed:   []string{`page.id > 10`, `page.wp_namespace == 0`},
+			wantResidual: true,
+		},
+		{
+			name:         "comprehension",
+			source:       `trigram.cell.exists(c, c.page_count > 1) && trigram.ngram != ""`,
+			want:         "`trigram`.`ngram` != \"\"",
+			wantPushed:   []string{`trigram.ngram != ""`},
+			wantResidual: true,
+		},
+		{
+			name:         "nonePushed",
+			source:       `page.title.isPalindrome()`,
+			want:         "TRUE",
+			wantResidual: true,
+		},
+		{
+			name:         "disjunction",
+			source:       `page.id > 10 || page.title.isPalindrome()`,
+			want:         "TRUE",
+			wantResidual: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ast, issues := env.Compile(tt.source)
+			require.Empty(t, issues)
+			got, err := cel2sql.SplitFilter(ast)
+			require.NoError(t, err)
+			sql, err := cel2sql.Convert(got.Filter)
+			if assert.NoError(t, err) {
+				assert.Equal(t, tt.want, sql)
+			}
+			assert.Equal(t, tt.wantPushed, got.Pushed)
+			assert.Equal(t, tt.wantResidual, got.Residual != nil)
+		})
+	}
+}
+
+func TestSplit_Program(t *testing.T) {
+	env, err := cel2sql.NewEnv(
+		map[string]bigquery.Schema{
+			"wikipedia": test.NewWikipediaTableMetadata().Schema,
+		},
+		cel2sql.TableVariable("page", "wikipedia"),
+		cel2sql.Declarations(
+			decls.NewFunction("isPalindrome",
+				decls.NewInstanceOverload("string_is_palindrome", []*exprpb.Type{decls.String}, decls.Bool),
+			),
+		),
+	)
+	require.NoError(t, err)
+	ast, issues := env.Compile(`page.id > 10 && page.title.isPalindrome()`)
+	require.Empty(t, issues)
+	split, err := cel2sql.SplitFilter(ast)
+	require.NoError(t, err)
+
+	program, err := split.Program(env, cel.Functions(&functions.Overload{
+		Operator: "string_is_palindrome",
+		Unary: func(value ref.Val) ref.Val {
+			s := []rune(value.(types.String))
+			for i := 0; i < len(s)/2; i++ {
+				if s[i] != s[len(s)-1-i] {
+					return types.False
+				}
+			}
+			return types.True
+		},
+	}))
+	require.NoError(t, err)
+	for title, want := range map[string]bool{"level": true, "page": false} {
+		out, _, err := program.Eval(map[string]interface{}{
+			"page": map[string]interface{}{"id": 100, "title": title},
+		})
+		if assert.NoError(t, err) {
+			assert.Equal(t, types.Bool(want), out)
+		}
+	}
+}
@@ -20,7 +20,8 @@ func newConstantString(str string) *expr.Constant {
 	return &expr.Constant{ConstantKind: &expr.Constant_StringValue{StringValue: str}}
 }
 
-var SQLTypeDeclarations = cel.Declarations(
+// Declarations are the constants and functions of Standard SQL, which are rendered in SQL as is.
+var Declarations = []*expr.Decl{
 	// constants
 	decls.NewConst("MICROSECOND", DatePart, newConstantString("MICROSECOND")),
 	decls.NewConst("MILLISECOND", DatePart, newConstantString("MILLISECOND")),
@@ -335,4 +336,6 @@ var SQLTypeDeclarations = cel.Declarations(
 	decls.NewFunction("soundex",
 		decls.NewOverload("bytes_soundex", []*expr.Type{decls.String}, decls.String),
 	),
-)
+}
+
+var SQLTypeDeclarations = cel.Declarations(Declarations...)